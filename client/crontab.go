@@ -24,6 +24,10 @@ type Retry struct {
 	Min *int `yaml:"min,omitempty" json:"min,omitempty"`
 	// Max is the maximum duration between each attempts in seconds.
 	Max *int `yaml:"max,omitempty" json:"max,omitempty"`
+	// Permanent is the list of HTTP status codes that must not be retried.
+	Permanent *string `yaml:"permanent,omitempty" json:"permanent,omitempty"`
+	// Retryable is the list of HTTP status codes that can be retried.
+	Retryable *string `yaml:"retryable,omitempty" json:"retryable,omitempty"`
 }
 
 type HTTPAuth struct {
@@ -78,11 +82,44 @@ type Task struct {
 	// Schedule is a cron specification describing the recurrency.
 	Schedule string `yaml:"schedule" json:"schedule"`
 
+	// Timezone is the IANA name of the timezone used to evaluate the Schedule.
+	Timezone *string `yaml:"timezone,omitempty" json:"timezone,omitempty"`
+
+	// Misfire is the policy for the runs missed while hooky was down: `skip`, `fire_once` or `fire_all`.
+	Misfire *string `yaml:"misfire,omitempty" json:"misfire,omitempty"`
+
+	// MisfireLimit is the maximum number of missed runs replayed with the `fire_all` policy.
+	MisfireLimit *int `yaml:"misfire_limit,omitempty" json:"misfireLimit,omitempty"`
+
+	// Overlap is the policy for the runs starting while the previous attempt is still running: `forbid`, `queue` or `allow`.
+	Overlap *string `yaml:"overlap,omitempty" json:"overlap,omitempty"`
+
+	// StartAt is the RFC3339 date before which the Schedule has no run.
+	StartAt *string `yaml:"start_at,omitempty" json:"startAt,omitempty"`
+
+	// EndAt is the RFC3339 date after which the Schedule has no run.
+	EndAt *string `yaml:"end_at,omitempty" json:"endAt,omitempty"`
+
+	// MaxRuns is the maximum number of runs of the Schedule.
+	MaxRuns *int `yaml:"max_runs,omitempty" json:"maxRuns,omitempty"`
+
+	// Jitter is the maximum delay in seconds added to the runs of the Schedule.
+	Jitter *int `yaml:"jitter,omitempty" json:"jitter,omitempty"`
+
+	// Priority is the priority of the attempts, higher priorities are executed first.
+	Priority *int `yaml:"priority,omitempty" json:"priority,omitempty"`
+
 	// Active is the task active.
 	Active *bool `yaml:"active,omitempty" json:"active,omitempty"`
 
 	// Retry is the retry strategy parameters in case of errors.
 	Retry *Retry `yaml:"retry,omitempty" json:"retry,omitempty"`
+
+	// Timeout is the maximum duration of an attempt in seconds.
+	Timeout *int `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+
+	// Success is the list of HTTP status codes considered as a success (ie: "2xx" or "200-299,304").
+	Success *string `yaml:"success,omitempty" json:"success,omitempty"`
 }
 
 type Crontab struct {
@@ -90,6 +127,7 @@ type Crontab struct {
 	TaskDefaults *TaskDefaults `yaml:"tasks_defaults,omitempty"`
 	Tasks        []*Task       `yaml:"tasks"`
 	Application  string        `yaml:"application"`
+	Timezone     string        `yaml:"timezone,omitempty"`
 }
 
 func NewCrontabFromFile(path string) (*Crontab, error) {
@@ -172,6 +210,9 @@ func SyncCrontab(baseURL string, crontab *Crontab) error {
 	// Set all the tasks from the crontab
 	for _, task := range crontab.Tasks {
 		currentTasks[task.Name] = true
+		if task.Timezone == nil && crontab.Timezone != "" {
+			task.Timezone = &crontab.Timezone
+		}
 		payload, err := json.Marshal(task)
 		if err != nil {
 			return err
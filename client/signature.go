@@ -0,0 +1,70 @@
+package hooky
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/sebest/hooky/models"
+)
+
+var (
+	// ErrMissingSignature is returned when a request has no signature.
+	ErrMissingSignature = errors.New("missing signature")
+	// ErrInvalidSignature is returned when no signature matches the secret.
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrExpiredSignature is returned when the signature timestamp is outside the tolerance.
+	ErrExpiredSignature = errors.New("expired signature")
+)
+
+// VerifySignature checks the value of the X-Hooky-Signature header of a
+// request given its method, its full URL and its body.
+func VerifySignature(header string, secret string, method string, url string, body []byte, tolerance time.Duration) error {
+	if header == "" {
+		return ErrMissingSignature
+	}
+	timestamp, signatures, err := models.ParseSignatureHeader(header)
+	if err != nil {
+		return err
+	}
+	if tolerance > 0 {
+		delta := time.Since(time.Unix(timestamp, 0))
+		if delta < 0 {
+			delta = -delta
+		}
+		if delta > tolerance {
+			return ErrExpiredSignature
+		}
+	}
+	expected := []byte(models.Signature(secret, method, url, timestamp, string(body)))
+	for _, signature := range signatures {
+		if hmac.Equal(expected, []byte(signature)) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// VerifyRequest checks the signature of a request received from Hooky.
+// The URL is rebuilt from the request, use VerifySignature when the
+// receiver is behind a proxy rewriting it.
+func VerifyRequest(r *http.Request, secret string, tolerance time.Duration) error {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(r.Body); err != nil {
+			return err
+		}
+		r.Body.Close()
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	url := scheme + "://" + r.Host + r.URL.RequestURI()
+	return VerifySignature(r.Header.Get(models.SignatureHeader), secret, r.Method, url, body, tolerance)
+}
@@ -0,0 +1,59 @@
+package hooky
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sebest/hooky/models"
+)
+
+func TestVerifySignature(t *testing.T) {
+	now := time.Now().Unix()
+	url := "http://example.com/hook?id=1"
+	body := []byte(`{"hello":"world"}`)
+	header := models.SignatureHeaderValue([]string{"new", "old"}, "POST", url, now, string(body))
+	expired := models.SignatureHeaderValue([]string{"new"}, "POST", url, now-3600, string(body))
+	tests := []struct {
+		name      string
+		header    string
+		secret    string
+		method    string
+		body      []byte
+		tolerance time.Duration
+		want      error
+	}{
+		{"current secret", header, "new", "POST", body, time.Minute, nil},
+		{"previous secret", header, "old", "POST", body, time.Minute, nil},
+		{"wrong secret", header, "other", "POST", body, time.Minute, ErrInvalidSignature},
+		{"wrong method", header, "new", "PUT", body, time.Minute, ErrInvalidSignature},
+		{"wrong body", header, "new", "POST", []byte(`{}`), time.Minute, ErrInvalidSignature},
+		{"missing", "", "new", "POST", body, time.Minute, ErrMissingSignature},
+		{"malformed", "v1", "new", "POST", body, time.Minute, models.ErrInvalidSignatureHeader},
+		{"no timestamp", "v1=abc", "new", "POST", body, time.Minute, models.ErrInvalidSignatureHeader},
+		{"expired", expired, "new", "POST", body, time.Minute, ErrExpiredSignature},
+		{"no tolerance", expired, "new", "POST", body, 0, nil},
+	}
+	for _, test := range tests {
+		if err := VerifySignature(test.header, test.secret, test.method, url, test.body, test.tolerance); err != test.want {
+			t.Errorf("%s: VerifySignature returned %v, want %v", test.name, err, test.want)
+		}
+	}
+}
+
+func TestVerifyRequest(t *testing.T) {
+	body := "payload"
+	r := httptest.NewRequest("POST", "http://example.com/hook?id=1", strings.NewReader(body))
+	timestamp := time.Now().Unix()
+	r.Header.Set(models.SignatureHeader, "t="+strconv.FormatInt(timestamp, 10)+",v1="+models.Signature("secret", "POST", "http://example.com/hook?id=1", timestamp, body))
+	if err := VerifyRequest(r, "secret", time.Minute); err != nil {
+		t.Fatalf("VerifyRequest returned %s", err)
+	}
+	// The body can still be read by the handler.
+	if b, _ := ioutil.ReadAll(r.Body); string(b) != body {
+		t.Errorf("body = %q, want %q", b, body)
+	}
+}
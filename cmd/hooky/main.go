@@ -4,8 +4,11 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"os"
+	"time"
 
 	"github.com/sebest/hooky/client"
+	"github.com/sebest/hooky/models"
 	"gopkg.in/yaml.v2"
 )
 
@@ -14,9 +17,64 @@ var (
 	crontabFile = flag.String("crontab-file", "", "load a crontab file")
 )
 
+// cronPreview validates a schedule and prints its next runs.
+func cronPreview(args []string) {
+	fs := flag.NewFlagSet("cron preview", flag.ExitOnError)
+	timezone := fs.String("timezone", "", "IANA name of the timezone used to evaluate the schedule")
+	jitter := fs.Int("jitter", 0, "maximum delay in seconds added to the runs")
+	count := fs.Int("count", 10, "number of runs to print")
+	startAt := fs.String("start-at", "", "RFC3339 date before which the schedule has no run")
+	endAt := fs.String("end-at", "", "RFC3339 date after which the schedule has no run")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: hooky cron preview [options] <schedule>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	var start, end int64
+	if *startAt != "" {
+		t, err := time.Parse(time.RFC3339, *startAt)
+		if err != nil {
+			log.Fatal(err)
+		}
+		start = t.UnixNano()
+	}
+	if *endAt != "" {
+		t, err := time.Parse(time.RFC3339, *endAt)
+		if err != nil {
+			log.Fatal(err)
+		}
+		end = t.UnixNano()
+	}
+	schedule, runs, err := models.PreviewSchedule(fs.Arg(0), *timezone, start, end, *jitter, "", time.Now(), *count)
+	if err != nil {
+		log.Fatalf("invalid schedule: %s", err)
+	}
+	loc, err := time.LoadLocation(*timezone)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(schedule)
+	for _, run := range runs {
+		fmt.Println(time.Unix(0, run).In(loc).Format(time.RFC3339))
+	}
+	if *jitter > 0 {
+		fmt.Printf("each run is delayed by up to %ds\n", *jitter)
+	}
+}
+
 func main() {
 	flag.Parse()
 
+	if flag.NArg() >= 2 && flag.Arg(0) == "cron" && flag.Arg(1) == "preview" {
+		cronPreview(flag.Args()[2:])
+		return
+	}
+
 	if *crontabFile != "" {
 		crontab, err := hooky.NewCrontabFromFile(*crontabFile)
 		if err != nil {
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"expvar"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"time"
+	// Embed the timezone database for the images without it.
+	_ "time/tzdata"
 
 	"github.com/codegangsta/cli"
 	"github.com/sebest/hooky/models"
@@ -14,6 +18,82 @@ import (
 	"github.com/stretchr/graceful"
 )
 
+var (
+	// apiFlags are the flags only used by the api role.
+	apiFlags = []string{"admin-password", "accesslog-format"}
+
+	// schedulerFlags are the flags only used by the scheduler role.
+	schedulerFlags = []string{"max-mongo-query", "max-http-request", "batch-size", "batch-wait", "touch-interval", "shutdown-grace-period", "clean-finished-attempts"}
+
+	// accessLogFormats are the valid formats of the access log.
+	accessLogFormats = map[string]bool{
+		"none":            true,
+		"json":            true,
+		"apache-fancy":    true,
+		"apache-combined": true,
+		"apache-common":   true,
+	}
+)
+
+// validateFlags checks the role and the flags used by the role.
+func validateFlags(c *cli.Context) error {
+	role := c.String("role")
+	var unused []string
+	switch role {
+	case "all":
+	case "api":
+		unused = schedulerFlags
+	case "scheduler":
+		unused = apiFlags
+	default:
+		return fmt.Errorf("invalid role %q: should be api, scheduler or all", role)
+	}
+	for _, name := range unused {
+		if changed(c, name) {
+			return fmt.Errorf("--%s is not used with the %s role", name, role)
+		}
+	}
+	if role != "scheduler" {
+		if c.String("admin-password") == "" {
+			return fmt.Errorf("--admin-password can not be empty")
+		}
+		if !accessLogFormats[c.String("accesslog-format")] {
+			return fmt.Errorf("invalid --accesslog-format %q", c.String("accesslog-format"))
+		}
+	}
+	if role != "api" {
+		for _, name := range []string{"max-mongo-query", "max-http-request", "batch-size", "touch-interval"} {
+			if c.Int(name) < 1 {
+				return fmt.Errorf("--%s should be at least 1", name)
+			}
+		}
+		for _, name := range []string{"batch-wait", "shutdown-grace-period", "clean-finished-attempts"} {
+			if c.Int(name) < 0 {
+				return fmt.Errorf("--%s can not be negative", name)
+			}
+		}
+	}
+	return nil
+}
+
+// changed reports whether a flag has another value than its default, the
+// values of the environment variables are the defaults of the flags.
+func changed(c *cli.Context, name string) bool {
+	for _, flag := range c.App.Flags {
+		switch f := flag.(type) {
+		case cli.IntFlag:
+			if f.Name == name {
+				return c.Int(name) != f.Value
+			}
+		case cli.StringFlag:
+			if f.Name == name {
+				return c.String(name) != f.Value
+			}
+		}
+	}
+	return false
+}
+
 func main() {
 	app := cli.NewApp()
 	app.Name = "hooky"
@@ -22,6 +102,12 @@ func main() {
 	app.Author = "Sébastien Estienne"
 	app.Email = "sebastien.estienne@gmail.com"
 	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:   "role",
+			Value:  "all",
+			Usage:  "role of the instance: api to serve the Rest API, scheduler to execute the attempts with a status listener, or all",
+			EnvVar: "HOOKY_ROLE",
+		},
 		cli.StringFlag{
 			Name:   "bind-address",
 			Value:  "",
@@ -64,12 +150,30 @@ func main() {
 			Usage:  "maximum number of parallel HTTP requests",
 			EnvVar: "HOOKY_MAX_HTTP_REQUEST",
 		},
+		cli.IntFlag{
+			Name:   "batch-size",
+			Value:  10,
+			Usage:  "maximum number of attempts reserved by a query on MongoDB",
+			EnvVar: "HOOKY_BATCH_SIZE",
+		},
+		cli.IntFlag{
+			Name:   "batch-wait",
+			Value:  10,
+			Usage:  "duration in milliseconds to wait for busy workers to fill a batch of attempts",
+			EnvVar: "HOOKY_BATCH_WAIT",
+		},
 		cli.IntFlag{
 			Name:   "touch-interval",
 			Value:  5,
 			Usage:  "frequency to update the tasks reservation duration in seconds",
 			EnvVar: "HOOKY_TOUCH_INTERVAL",
 		},
+		cli.IntFlag{
+			Name:   "shutdown-grace-period",
+			Value:  30,
+			Usage:  "duration in seconds to wait for the running attempts on shutdown before aborting them",
+			EnvVar: "HOOKY_SHUTDOWN_GRACE_PERIOD",
+		},
 		cli.IntFlag{
 			Name:   "clean-finished-attempts",
 			Value:  7 * 24,
@@ -78,6 +182,11 @@ func main() {
 		},
 	}
 	app.Action = func(c *cli.Context) {
+		if err := validateFlags(c); err != nil {
+			log.Fatal(err)
+		}
+		role := c.String("role")
+
 		s, err := store.New(c.String("mongo-uri"))
 		if err != nil {
 			log.Fatal(err)
@@ -89,17 +198,34 @@ func main() {
 		}
 		db.Session.Close()
 
-		sched := scheduler.New(s, c.Int("max-mongo-query"), c.Int("max-http-request"), c.Int("touch-interval"), c.Int("clean-finished-attempts")*3600)
-		sched.Start()
-		ra, err := restapi.New(s, c.String("admin-password"), c.String("accesslog-format"))
-		if err != nil {
-			log.Fatal(err)
+		var sched *scheduler.Scheduler
+		if role != "api" {
+			sched = scheduler.New(s, c.Int("max-mongo-query"), c.Int("max-http-request"), c.Int("touch-interval"), c.Int("clean-finished-attempts")*3600, c.Int("shutdown-grace-period"), c.Int("batch-size"), c.Int("batch-wait"))
+			sched.Start()
+		}
+		var handler http.Handler
+		if role == "scheduler" {
+			// The scheduler only serves its status and metrics.
+			st, err := restapi.NewStatus(s, role)
+			if err != nil {
+				log.Fatal(err)
+			}
+			mux := http.NewServeMux()
+			mux.Handle("/status", st.MakeHandler())
+			mux.Handle("/debug/vars", expvar.Handler())
+			handler = mux
+		} else {
+			ra, err := restapi.New(s, c.String("admin-password"), c.String("accesslog-format"), role)
+			if err != nil {
+				log.Fatal(err)
+			}
+			handler = ra.MakeHandler()
 		}
 		server := &graceful.Server{
 			Timeout: 10 * time.Second,
 			Server: &http.Server{
 				Addr:    c.String("bind-host") + ":" + c.String("bind-port"),
-				Handler: ra.MakeHandler(),
+				Handler: handler,
 			},
 		}
 		err = server.ListenAndServe()
@@ -107,7 +233,9 @@ func main() {
 			log.Println(err)
 		}
 		log.Println("exiting...")
-		sched.Stop()
+		if sched != nil {
+			sched.Stop()
+		}
 		log.Println("exited")
 	}
 	app.Run(os.Args)
@@ -1,12 +1,18 @@
 package models
 
 import (
+	"errors"
 	"math/rand"
 
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
 
+var (
+	// ErrInvalidMaxInFlightPerHost is returned when the maximum number of attempts in flight per host is negative.
+	ErrInvalidMaxInFlightPerHost = errors.New("invalid max in flight per host")
+)
+
 // Account is an account to access the service.
 type Account struct {
 	// ID is the ID of the Account.
@@ -20,6 +26,10 @@ type Account struct {
 
 	// Deleted
 	Deleted bool `bson:"deleted"`
+
+	// MaxInFlightPerHost is the maximum number of attempts executed in
+	// parallel for a target host across all the queues, 0 means no limit.
+	MaxInFlightPerHost int `bson:"max_in_flight_per_host,omitempty"`
 }
 
 // NewAccount creates a new Account.
@@ -35,15 +45,23 @@ func (b *Base) NewAccount(name *string) (account *Account, err error) {
 }
 
 // UpdateAccount updates an Account.
-func (b *Base) UpdateAccount(accountID bson.ObjectId, name *string) (account *Account, err error) {
-	if name == nil {
+func (b *Base) UpdateAccount(accountID bson.ObjectId, name *string, maxInFlightPerHost *int) (account *Account, err error) {
+	update := bson.M{}
+	if name != nil {
+		update["name"] = name
+	}
+	if maxInFlightPerHost != nil {
+		if *maxInFlightPerHost < 0 {
+			return nil, ErrInvalidMaxInFlightPerHost
+		}
+		update["max_in_flight_per_host"] = *maxInFlightPerHost
+	}
+	if len(update) == 0 {
 		return b.GetAccount(accountID)
 	}
 	change := mgo.Change{
 		Update: bson.M{
-			"$set": bson.M{
-				"name": name,
-			},
+			"$set": update,
 		},
 		ReturnNew: true,
 	}
@@ -53,6 +71,20 @@ func (b *Base) UpdateAccount(accountID bson.ObjectId, name *string) (account *Ac
 	account = &Account{}
 	_, err = b.db.C("accounts").Find(query).Apply(change, account)
 	_, err = b.ShouldRefreshSession(err)
+	if err != nil || maxInFlightPerHost == nil {
+		return
+	}
+	// The Queues keep a copy of the limit to admit their attempts.
+	query = bson.M{
+		"account": accountID,
+	}
+	update = bson.M{
+		"$set": bson.M{
+			"max_in_flight_per_host": *maxInFlightPerHost,
+		},
+	}
+	_, err = b.db.C("queues").UpdateAll(query, update)
+	_, err = b.ShouldRefreshSession(err)
 	return
 }
 
@@ -2,6 +2,7 @@ package models
 
 import (
 	"errors"
+	"time"
 
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
@@ -14,6 +15,12 @@ var (
 	ErrApplicationNotFound = errors.New("application does not exist")
 )
 
+const (
+	// DefaultSecretGracePeriod is the duration in seconds during which the
+	// previous secret is still used to sign the requests after a rotation.
+	DefaultSecretGracePeriod = 24 * 3600
+)
+
 // Application is a list of recurring Tasks.
 type Application struct {
 	// ID is the ID of the Application.
@@ -25,19 +32,62 @@ type Application struct {
 	// Name is the Application's name.
 	Name string `bson:"name"`
 
+	// Timezone is the IANA name of the default timezone of the Tasks.
+	Timezone string `bson:"timezone,omitempty"`
+
+	// Secret is the secret key used to sign the requests.
+	Secret string `bson:"secret"`
+
+	// PreviousSecret is the secret key that was used before the last rotation.
+	PreviousSecret string `bson:"previous_secret,omitempty"`
+
+	// PreviousSecretExpires is a Unix timestamp until when the previous secret is used.
+	PreviousSecretExpires int64 `bson:"previous_secret_expires,omitempty"`
+
+	// Paused is true when the dispatch of the attempts is paused.
+	Paused bool `bson:"paused"`
+
+	// PausedAt is a Unix timestamp of the time the Application was paused.
+	PausedAt int64 `bson:"paused_at,omitempty"`
+
 	// Deleted
 	Deleted bool `bson:"deleted"`
 }
 
 // NewApplication creates a new Application.
-func (b *Base) NewApplication(account bson.ObjectId, name string) (application *Application, err error) {
+func (b *Base) NewApplication(account bson.ObjectId, name string, timezone string) (application *Application, err error) {
+	if _, err = loadLocation(timezone); err != nil {
+		return nil, err
+	}
+	secret, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
 	application = &Application{
-		ID:      bson.NewObjectId(),
-		Account: account,
-		Name:    name,
+		ID:       bson.NewObjectId(),
+		Account:  account,
+		Name:     name,
+		Timezone: timezone,
+		Secret:   secret,
 	}
 	err = b.db.C("applications").Insert(application)
 	_, err = b.ShouldRefreshSession(err)
+	if mgo.IsDup(err) {
+		change := mgo.Change{
+			Update: bson.M{
+				"$set": bson.M{
+					"timezone": timezone,
+				},
+			},
+			ReturnNew: true,
+		}
+		query := bson.M{
+			"account": account,
+			"name":    name,
+		}
+		_, err = b.db.C("applications").Find(query).Apply(change, application)
+		_, err = b.ShouldRefreshSession(err)
+	}
 	return
 }
 
@@ -58,6 +108,58 @@ func (b *Base) GetApplication(account bson.ObjectId, name string) (application *
 	return
 }
 
+// Secrets returns the secrets that must be used to sign the requests.
+func (a *Application) Secrets() []string {
+	secrets := []string{a.Secret}
+	if a.PreviousSecret != "" && a.PreviousSecretExpires > time.Now().Unix() {
+		secrets = append(secrets, a.PreviousSecret)
+	}
+	return secrets
+}
+
+// RotateApplicationSecret generates a new secret for an Application, the
+// previous secret is still used during gracePeriod seconds.
+func (b *Base) RotateApplicationSecret(account bson.ObjectId, name string, gracePeriod int) (application *Application, err error) {
+	application, err = b.GetApplication(account, name)
+	if application == nil || err != nil {
+		return
+	}
+	if gracePeriod == 0 {
+		gracePeriod = DefaultSecretGracePeriod
+	}
+	secret, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+	previousSecret := application.Secret
+	previousSecretExpires := time.Now().Unix() + int64(gracePeriod)
+	if gracePeriod < 0 {
+		previousSecret = ""
+		previousSecretExpires = 0
+	}
+	change := mgo.Change{
+		Update: bson.M{
+			"$set": bson.M{
+				"secret":                  secret,
+				"previous_secret":         previousSecret,
+				"previous_secret_expires": previousSecretExpires,
+			},
+		},
+		ReturnNew: true,
+	}
+	query := bson.M{
+		"_id":    application.ID,
+		"secret": application.Secret,
+	}
+	_, err = b.db.C("applications").Find(query).Apply(change, application)
+	_, err = b.ShouldRefreshSession(err)
+	if err == mgo.ErrNotFound {
+		err = nil
+		application = nil
+	}
+	return
+}
+
 // DeleteApplication deletes an Application and all its children.
 func (b *Base) DeleteApplication(account bson.ObjectId, name string) (err error) {
 	if name == "default" {
@@ -1,8 +1,12 @@
 package models
 
 import (
+	"context"
+	"errors"
 	"expvar"
+	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"strings"
 	"time"
@@ -15,8 +19,17 @@ import (
 var (
 	statsAttemptsSuccess = expvar.NewInt("attemptsSuccess")
 	statsAttemptsError   = expvar.NewInt("attemptsError")
+	statsAttemptsAborted = expvar.NewInt("attemptsAborted")
 	// ModelsAttemptDebug ...
 	ModelsAttemptDebug = debug.Debug("hooky.models.attempt")
+
+	// ErrAttemptAborted is the cause of the abort of an attempt requested through the API.
+	ErrAttemptAborted = errors.New("abort requested")
+	// ErrShutdown is the cause of the abort of the attempts still running
+	// at the end of the grace period of a shutdown.
+	ErrShutdown = errors.New("hookyd is shutting down")
+	// ErrAttemptNotRunning is returned when aborting an attempt that is not running.
+	ErrAttemptNotRunning = errors.New("attempt is not running")
 )
 
 // AttemptStatuses
@@ -25,6 +38,8 @@ var AttemptStatuses = map[string]bool{
 	"running": true,
 	"success": true,
 	"error":   true,
+	"skipped": true,
+	"aborted": true,
 }
 
 // Attempt describes a HTTP request that must be perform for a task.
@@ -53,6 +68,9 @@ type Attempt struct {
 	// URL is the URL that the worker with requests.
 	URL string `bson:"url"`
 
+	// Host is the scheme, host and port of the URL.
+	Host string `bson:"host,omitempty"`
+
 	// HTTPAuth is the HTTP authentication to use if any.
 	HTTPAuth HTTPAuth `json:"auth"`
 
@@ -65,15 +83,42 @@ type Attempt struct {
 	// Payload is a arbitrary data that will be POSTed on the URL.
 	Payload string `bson:"payload"`
 
+	// Timeout is the maximum duration of the request in seconds.
+	Timeout int `bson:"timeout,omitempty"`
+
+	// Success is the list of HTTP status codes considered as a success.
+	Success string `bson:"success,omitempty"`
+
 	// Reserved is a Unix timestamp until when the attempt is reserved by a worker.
 	Reserved int64 `bson:"reserved"`
 
+	// Reservation is the token of the batch that reserved the attempt.
+	Reservation bson.ObjectId `bson:"reservation,omitempty"`
+
 	// At is a Unix timestamp representing the time a request must be performed.
 	At int64 `bson:"at"`
 
+	// ScheduledAt is a Unix timestamp in nanoseconds of the run of the schedule this attempt belongs to.
+	ScheduledAt int64 `bson:"scheduled_at,omitempty"`
+
+	// Started is a Unix timestamp in nanoseconds representing the time the attempt started.
+	Started int64 `bson:"started,omitempty"`
+
 	// Finished is a Unix timestamp representing the time the attempt finished.
 	Finished int64 `bson:"finished,omitempty"`
 
+	// Retries is the number of retries of the run this attempt belongs to.
+	Retries int `bson:"retries,omitempty"`
+
+	// Priority is the priority of the attempt, higher priorities are executed first.
+	Priority int `bson:"priority"`
+
+	// AbortRequested is true when the abort of the request was requested.
+	AbortRequested bool `bson:"abort_requested,omitempty"`
+
+	// Aged is a Unix timestamp in nanoseconds of the last time the priority was raised by the aging.
+	Aged int64 `bson:"aged,omitempty"`
+
 	// Status is either `pending`, `running`, `success` or `error`
 	Status string `bson:"status"`
 
@@ -83,11 +128,43 @@ type Attempt struct {
 	// StatusMessage is a human readable message related to the Status.
 	StatusMessage string `bson:"status_message,omitempty"`
 
+	// RetryAfter is a Unix timestamp in nanoseconds requested by the server
+	// with a Retry-After header.
+	RetryAfter int64 `bson:"retry_after,omitempty"`
+
+	// Permanent is true when the attempt failed in a way no retry can fix.
+	Permanent bool `bson:"permanent,omitempty"`
+
+	// ResponseBody is the beginning of the body of the HTTP response.
+	ResponseBody string `bson:"response_body,omitempty"`
+
+	// ResponseHeaders are the headers of the HTTP response.
+	ResponseHeaders map[string]string `bson:"response_headers,omitempty"`
+
+	// Timing is the timing breakdown of the HTTP request.
+	Timing *Timing `bson:"timing,omitempty"`
+
 	// Acked
 	Acked bool `bson:"acked"`
 
 	// Deleted
 	Deleted bool `bson:"deleted"`
+
+	// queue is the Queue of the attempt loaded when it was admitted.
+	queue *Queue
+}
+
+// isSuccess returns true if the HTTP status code is considered as a success.
+func (a *Attempt) isSuccess(statusCode int) bool {
+	success := a.Success
+	if success == "" {
+		success = DefaultSuccess
+	}
+	codes, err := ParseStatusCodes(success)
+	if err != nil {
+		return false
+	}
+	return codes.Match(statusCode)
 }
 
 // NewAttempt creates a new Attempt.
@@ -109,12 +186,17 @@ func (b *Base) NewAttempt(task *Task, deletePending bool, force bool) (*Attempt,
 		QueueID:     task.QueueID,
 		Task:        task.Name,
 		URL:         task.URL,
+		Host:        hostKey(task.URL),
 		HTTPAuth:    task.HTTPAuth,
 		Method:      task.Method,
 		Headers:     task.Headers,
 		Payload:     task.Payload,
+		Timeout:     task.Timeout,
+		Success:     task.Success,
 		Reserved:    task.At,
 		At:          task.At,
+		ScheduledAt: task.ScheduledAt,
+		Priority:    task.Priority,
 		Status:      "pending",
 	}
 	if err := b.db.C("attempts").Insert(attempt); err != nil {
@@ -124,6 +206,7 @@ func (b *Base) NewAttempt(task *Task, deletePending bool, force bool) (*Attempt,
 	if err := b.SetAttemptQueuedForTask(task); err != nil {
 		return nil, err
 	}
+	notifyDueAttempt(attempt)
 	return attempt, nil
 }
 
@@ -203,13 +286,18 @@ func (b *Base) GetAttempts(account bson.ObjectId, application string, task strin
 
 // NextAttempt reserves and returns the next Attempt.
 func (b *Base) NextAttempt(ttr int64) (*Attempt, error) {
-	var fullQueues []bson.ObjectId
+	// The attempts of the paused Queues and Applications are skipped.
+	fullQueues, excluded, err := b.pausedRessources()
+	if err != nil {
+		return nil, err
+	}
 	now := time.Now().UnixNano()
 	change := mgo.Change{
 		Update: bson.M{
 			"$set": bson.M{
 				"reserved": now + (ttr * 1000000000),
 				"status":   "running",
+				"started":  now,
 			},
 		},
 		ReturnNew: true,
@@ -223,8 +311,12 @@ func (b *Base) NextAttempt(ttr int64) (*Attempt, error) {
 		if len(fullQueues) > 0 {
 			query["queue_id"] = bson.M{"$nin": fullQueues}
 		}
+		if len(excluded) > 0 {
+			query["$nor"] = excluded
+		}
 		attempt := &Attempt{}
-		_, err := b.db.C("attempts").Find(query).Sort("reserved").Apply(change, attempt)
+		// Higher priorities first and FIFO within a priority.
+		_, err := b.db.C("attempts").Find(query).Sort("-priority", "reserved").Apply(change, attempt)
 		_, err = b.ShouldRefreshSession(err)
 		if err == mgo.ErrNotFound {
 			return nil, nil
@@ -233,95 +325,274 @@ func (b *Base) NextAttempt(ttr int64) (*Attempt, error) {
 			return nil, err
 		}
 
-		full, err := b.EnQueue(attempt.QueueID, attempt.ID)
+		result, err := b.admitAttempt(attempt, now)
 		if err != nil {
 			return nil, err
 		}
-		if !full {
+		switch result {
+		case admitted:
 			return attempt, nil
+		case hostFull:
+			excluded = append(excluded, bson.M{"account": attempt.Account, "host": attempt.Host})
+		default:
+			fullQueues = append(fullQueues, attempt.QueueID)
 		}
+	}
+}
+
+// admission is the result of the admission of a reserved attempt.
+type admission int
+
+const (
+	// admitted attempts can be executed.
+	admitted admission = iota
+	// queueFull attempts stay reserved, their queue reached its max_in_flight.
+	queueFull
+	// hostFull attempts are released until their host has a free slot.
+	hostFull
+	// queueThrottled attempts are released until a token of their queue is available.
+	queueThrottled
+)
+
+// admitAttempt takes the slots of a reserved attempt in its queue and its host
+// and a token of its queue.
+func (b *Base) admitAttempt(attempt *Attempt, now int64) (admission, error) {
+	queue, full, err := b.EnQueue(attempt.QueueID, attempt.ID)
+	if err != nil {
+		return queueFull, err
+	}
+	if full {
 		ModelsAttemptDebug("Queue %s full", attempt.QueueID.Hex())
-		fullQueues = append(fullQueues, attempt.QueueID)
+		return queueFull, nil
+	}
+	attempt.queue = queue
+	full, err = b.EnHost(attempt, queue)
+	if err != nil {
+		return hostFull, err
+	}
+	if full {
+		// The attempt stays pending until the host has a free slot.
+		ModelsAttemptDebug("Host %s full", attempt.Host)
+		if err := b.DeQueue(attempt.QueueID, attempt.ID); err != nil {
+			return hostFull, err
+		}
+		return hostFull, b.ReleaseAttempt(attempt.ID, now+saturatedHostDelay)
+	}
+	throttled, wait, err := b.TakeToken(queue)
+	if err != nil {
+		return queueThrottled, err
+	}
+	if !throttled {
+		return admitted, nil
+	}
+	// The attempt stays pending until a token is available.
+	ModelsAttemptDebug("Queue %s throttled", attempt.QueueID.Hex())
+	if err := b.DeQueue(attempt.QueueID, attempt.ID); err != nil {
+		return queueThrottled, err
+	}
+	if err := b.DeHost(attempt); err != nil {
+		return queueThrottled, err
 	}
+	return queueThrottled, b.ReleaseAttempt(attempt.ID, now+wait)
 }
 
-// DoAttempt executes the attempt.
-func (b *Base) DoAttempt(attempt *Attempt) error {
-	var status string
-	var statusMessage string
-	var statusCode int
-	if strings.HasPrefix(attempt.URL, "test://") {
-		ModelsAttemptDebug("Test attempt %s starting", attempt.URL)
-		time.Sleep(10 * time.Second)
-		status = "success"
-		statusCode = 200
-		statusMessage = "Test attempt"
-		ModelsAttemptDebug("Test attempt %s done", attempt.URL)
-	} else {
-		ModelsAttemptDebug("Starting attempt [%s] for task %s", attempt.ID.Hex(), attempt.Task)
-		var data io.Reader
-		contentType := "text/plain"
-		if attempt.Method == "POST" && attempt.Payload != "" {
-			data = strings.NewReader(attempt.Payload)
-			if attempt.Payload[0] == '{' {
-				contentType = "application/json"
-			}
+// NextAttempts reserves up to max due attempts in batches and returns the
+// ones admitted by their queue, their host and their rate limit. The
+// attempts of a batch are claimed atomically with a reservation token so the
+// batches of concurrent queriers never overlap. Like NextAttempt, the full
+// queues and the saturated hosts are excluded from the next batches.
+func (b *Base) NextAttempts(ttr int64, max int) ([]*Attempt, error) {
+	// The attempts of the paused Queues and Applications are skipped.
+	fullQueues, excluded, err := b.pausedRessources()
+	if err != nil {
+		return nil, err
+	}
+	// The Queues without a free slot are skipped.
+	var queues []Queue
+	err = b.db.C("queues").Find(bson.M{"available_in_flight": bson.M{"$lte": 0}}).Select(bson.M{"_id": 1}).All(&queues)
+	if _, err = b.ShouldRefreshSession(err); err != nil {
+		return nil, err
+	}
+	for _, queue := range queues {
+		fullQueues = append(fullQueues, queue.ID)
+	}
+
+	now := time.Now().UnixNano()
+	var attempts []*Attempt
+	for len(attempts) < max {
+		query := bson.M{
+			"status":   bson.M{"$in": []string{"pending", "running"}},
+			"reserved": bson.M{"$lt": now},
+			"deleted":  false,
 		}
-		req, err := http.NewRequest(attempt.Method, attempt.URL, data)
-		if err != nil {
-			return err
+		if len(fullQueues) > 0 {
+			query["queue_id"] = bson.M{"$nin": fullQueues}
 		}
-		req.Header.Add("User-Agent", "Hooky")
-		req.Header.Add("X-Hooky-Account", attempt.Account.Hex())
-		req.Header.Add("X-Hooky-Application", attempt.Application)
-		req.Header.Add("X-Hooky-Queue", attempt.Queue)
-		req.Header.Add("X-Hooky-Task-Name", attempt.Task)
-		req.Header.Add("X-Hooky-Attempt-ID", attempt.ID.Hex())
-		req.Header.Add("Content-Type", contentType)
-		for k, v := range attempt.Headers {
-			req.Header.Add(k, v)
+		if len(excluded) > 0 {
+			query["$nor"] = excluded
 		}
-		if attempt.HTTPAuth.Username != "" || attempt.HTTPAuth.Password != "" {
-			req.SetBasicAuth(attempt.HTTPAuth.Username, attempt.HTTPAuth.Password)
+		var candidates []Attempt
+		// Higher priorities first and FIFO within a priority.
+		err = b.db.C("attempts").Find(query).Sort("-priority", "reserved").Select(bson.M{"_id": 1, "reserved": 1}).Limit(max - len(attempts)).All(&candidates)
+		if _, err = b.ShouldRefreshSession(err); err != nil {
+			return attempts, err
 		}
-		client := &http.Client{}
-		resp, err := client.Do(req)
-		if err != nil {
-			status = "error"
-			statusMessage = err.Error()
-		} else {
-			defer resp.Body.Close()
-			statusMessage = resp.Status
-			statusCode = resp.StatusCode
-			if statusCode == 200 {
-				status = "success"
-			} else {
-				status = "error"
+		if len(candidates) == 0 {
+			break
+		}
+		ids := make([]bson.ObjectId, len(candidates))
+		for idx, candidate := range candidates {
+			ids[idx] = candidate.ID
+		}
+
+		token := bson.NewObjectId()
+		query["_id"] = bson.M{"$in": ids}
+		update := bson.M{
+			"$set": bson.M{
+				"reserved":    now + (ttr * 1000000000),
+				"status":      "running",
+				"started":     now,
+				"reservation": token,
+			},
+		}
+		_, err = b.db.C("attempts").UpdateAll(query, update)
+		if _, err = b.ShouldRefreshSession(err); err != nil {
+			return attempts, err
+		}
+		var reserved []*Attempt
+		query = bson.M{
+			"_id":         bson.M{"$in": ids},
+			"reservation": token,
+		}
+		err = b.db.C("attempts").Find(query).All(&reserved)
+		if _, err = b.ShouldRefreshSession(err); err != nil {
+			return attempts, err
+		}
+		byID := make(map[bson.ObjectId]*Attempt, len(reserved))
+		for _, attempt := range reserved {
+			byID[attempt.ID] = attempt
+		}
+
+		full := make(map[bson.ObjectId]bool)
+		saturated := make(map[string]bool)
+		for _, candidate := range candidates {
+			attempt := byID[candidate.ID]
+			if attempt == nil {
+				// reserved by a concurrent querier
+				continue
+			}
+			hostID := attempt.Account.Hex() + " " + attempt.Host
+			if full[attempt.QueueID] || (attempt.Host != "" && saturated[hostID]) {
+				// The attempt keeps its place until its queue or its host is free.
+				if err := b.ReleaseAttempt(attempt.ID, candidate.Reserved); err != nil {
+					return attempts, err
+				}
+				continue
+			}
+			result, err := b.admitAttempt(attempt, now)
+			if err != nil {
+				return attempts, err
+			}
+			switch result {
+			case admitted:
+				attempts = append(attempts, attempt)
+			case queueFull:
+				full[attempt.QueueID] = true
+				fullQueues = append(fullQueues, attempt.QueueID)
+				if err := b.ReleaseAttempt(attempt.ID, candidate.Reserved); err != nil {
+					return attempts, err
+				}
+			case hostFull:
+				saturated[hostID] = true
+				excluded = append(excluded, bson.M{"account": attempt.Account, "host": attempt.Host})
+			case queueThrottled:
+				full[attempt.QueueID] = true
+				fullQueues = append(fullQueues, attempt.QueueID)
 			}
 		}
-		ModelsAttemptDebug("Attempt [%s] %s %s : %d -> %s", attempt.ID.Hex(), attempt.Method, attempt.URL, statusCode, status)
 	}
+	return attempts, nil
+}
+
+// DoAttempt executes the attempt, the request is aborted when the context is
+// canceled and the attempt gets the `aborted` status.
+func (b *Base) DoAttempt(ctx context.Context, attempt *Attempt) error {
+	ModelsAttemptDebug("Starting attempt [%s] for task %s", attempt.ID.Hex(), attempt.Task)
+	// The Queue was loaded when the attempt was admitted.
+	queue := attempt.queue
+	if queue == nil {
+		var err error
+		if queue, err = b.GetQueueByID(attempt.QueueID); err != nil {
+			return b.releaseSlots(attempt, err)
+		}
+	}
+	application, err := b.GetApplication(attempt.Account, attempt.Application)
+	if err != nil {
+		return b.releaseSlots(attempt, err)
+	}
+	if err := b.scheduleParallelRun(attempt); err != nil {
+		log.Printf("DoAttempt error while scheduling the next run: %s\n", err)
+	}
+	var result *ExecutorResult
+	req, options, err := newAttemptRequest(attempt, queue, application)
+	if err != nil {
+		// The attempt fails but its slots are still released.
+		result = &ExecutorResult{
+			StatusMessage: fmt.Sprintf("can not build the request: %s", err),
+			Permanent:     true,
+		}
+	} else if executor := GetExecutor(req.URL.Scheme); executor != nil {
+		result = executor.Execute(ctx, req, options)
+	} else {
+		result = &ExecutorResult{
+			StatusMessage: fmt.Sprintf("%s: %s", ErrUnsupportedScheme, req.URL.Scheme),
+			Permanent:     true,
+		}
+	}
+	status := "error"
+	if result.StatusCode != 0 && attempt.isSuccess(result.StatusCode) {
+		status = "success"
+	} else if result.StatusCode == 0 && ctx.Err() != nil {
+		status = "aborted"
+		// Without a requested abort the attempt was aborted by a shutdown.
+		cause := ErrShutdown
+		if current, _ := b.GetAttempt(attempt.ID); current != nil && current.abortRequested() {
+			cause = ErrAttemptAborted
+		}
+		result.StatusMessage = "aborted: " + cause.Error()
+	}
+	ModelsAttemptDebug("Attempt [%s] %s %s : %d -> %s", attempt.ID.Hex(), attempt.Method, attempt.URL, result.StatusCode, status)
 
 	if err := b.DeQueue(attempt.QueueID, attempt.ID); err != nil {
 		return err
 	}
+	if err := b.DeHost(attempt); err != nil {
+		return err
+	}
 	if status == "success" {
 		statsAttemptsSuccess.Add(1)
+	} else if status == "aborted" {
+		statsAttemptsAborted.Add(1)
 	} else {
 		statsAttemptsError.Add(1)
 	}
 	change := mgo.Change{
 		Update: bson.M{
 			"$set": bson.M{
-				"finished":       time.Now().Unix(),
-				"status":         status,
-				"status_code":    statusCode,
-				"status_message": statusMessage,
+				"finished":         time.Now().Unix(),
+				"status":           status,
+				"status_code":      result.StatusCode,
+				"status_message":   result.StatusMessage,
+				"response_body":    result.ResponseBody,
+				"response_headers": result.ResponseHeaders,
+				"timing":           result.Timing,
+				"retry_after":      result.RetryAfter,
+				"permanent":        result.Permanent,
 			},
 		},
 		ReturnNew: true,
 	}
-	_, err := b.db.C("attempts").FindId(attempt.ID).Apply(change, attempt)
+	_, err = b.db.C("attempts").FindId(attempt.ID).Apply(change, attempt)
 	_, err = b.ShouldRefreshSession(err)
 	if err != nil {
 		return err
@@ -329,16 +600,160 @@ func (b *Base) DoAttempt(attempt *Attempt) error {
 	return nil
 }
 
-// TouchAttempt reserves an attemptsttempt for more time.
-func (b *Base) TouchAttempt(attemptID bson.ObjectId, seconds int64) error {
-	update := bson.M{
-		"$set": bson.M{
-			"reserved": time.Now().UnixNano() + (seconds * 1000000000),
+// releaseSlots releases the slots of an attempt that can not be executed
+// because of a store error, the attempt is retried when its reservation
+// expires.
+func (b *Base) releaseSlots(attempt *Attempt, cause error) error {
+	if err := b.DeQueue(attempt.QueueID, attempt.ID); err != nil {
+		return err
+	}
+	if err := b.DeHost(attempt); err != nil {
+		return err
+	}
+	return cause
+}
+
+// newAttemptRequest returns the request and the options to execute an
+// attempt of a Queue and an Application.
+func newAttemptRequest(attempt *Attempt, queue *Queue, application *Application) (*http.Request, ExecutorOptions, error) {
+	options := ExecutorOptions{
+		Timeout:            time.Duration(attempt.Timeout) * time.Second,
+		MaxResponseBody:    DefaultMaxResponseBody,
+		MaxResponseHeaders: DefaultMaxResponseHeaders,
+	}
+	if options.Timeout == 0 {
+		options.Timeout = DefaultTimeout * time.Second
+	}
+	if queue != nil {
+		if queue.MaxResponseBody != 0 {
+			options.MaxResponseBody = queue.MaxResponseBody
+		}
+		if queue.MaxResponseHeaders != 0 {
+			options.MaxResponseHeaders = queue.MaxResponseHeaders
+		}
+	}
+
+	var data io.Reader
+	var payload string
+	contentType := "text/plain"
+	if attempt.Method == "POST" && attempt.Payload != "" {
+		payload = attempt.Payload
+		data = strings.NewReader(payload)
+		if attempt.Payload[0] == '{' {
+			contentType = "application/json"
+		}
+	}
+	req, err := http.NewRequest(attempt.Method, attempt.URL, data)
+	if err != nil {
+		return nil, options, err
+	}
+	req.Header.Add("User-Agent", "Hooky")
+	req.Header.Add("X-Hooky-Account", attempt.Account.Hex())
+	req.Header.Add("X-Hooky-Application", attempt.Application)
+	req.Header.Add("X-Hooky-Queue", attempt.Queue)
+	req.Header.Add("X-Hooky-Task-Name", attempt.Task)
+	req.Header.Add("X-Hooky-Attempt-ID", attempt.ID.Hex())
+	req.Header.Add("Content-Type", contentType)
+	if attempt.ScheduledAt > 0 {
+		req.Header.Add("X-Hooky-Scheduled-At", time.Unix(0, attempt.ScheduledAt).UTC().Format(time.RFC3339))
+	}
+	for k, v := range attempt.Headers {
+		req.Header.Add(k, v)
+	}
+	if attempt.HTTPAuth.Username != "" || attempt.HTTPAuth.Password != "" {
+		req.SetBasicAuth(attempt.HTTPAuth.Username, attempt.HTTPAuth.Password)
+	}
+	if application != nil && application.Secret != "" {
+		signature := SignatureHeaderValue(application.Secrets(), attempt.Method, attempt.URL, time.Now().Unix(), payload)
+		req.Header.Set(SignatureHeader, signature)
+	}
+	return req, options, nil
+}
+
+// TouchAttempt extends the reservation of an attempt and reports whether
+// its abort was requested or it was deleted.
+func (b *Base) TouchAttempt(attemptID bson.ObjectId, seconds int64) (abort bool, err error) {
+	change := mgo.Change{
+		Update: bson.M{
+			"$set": bson.M{
+				"reserved": time.Now().UnixNano() + (seconds * 1000000000),
+			},
+		},
+		ReturnNew: true,
+	}
+	attempt := &Attempt{}
+	_, err = b.db.C("attempts").FindId(attemptID).Apply(change, attempt)
+	_, err = b.ShouldRefreshSession(err)
+	if err != nil {
+		return false, err
+	}
+	return attempt.abortRequested(), nil
+}
+
+// abortRequested reports whether the abort of an attempt was requested
+// through the API or its Task was deleted.
+func (a *Attempt) abortRequested() bool {
+	return a.AbortRequested || a.Deleted
+}
+
+// AbortAttempt requests the abort of the request of a running attempt.
+func (b *Base) AbortAttempt(account bson.ObjectId, application string, task string, attemptID bson.ObjectId) (attempt *Attempt, err error) {
+	query := bson.M{
+		"_id":         attemptID,
+		"account":     account,
+		"application": application,
+		"task":        task,
+		"deleted":     false,
+	}
+	attempt = &Attempt{}
+	if err = b.db.C("attempts").Find(query).One(attempt); err != nil {
+		_, err = b.ShouldRefreshSession(err)
+		attempt = nil
+		if err == mgo.ErrNotFound {
+			err = nil
+		}
+		return
+	}
+	if attempt.Status != "running" {
+		return nil, ErrAttemptNotRunning
+	}
+	change := mgo.Change{
+		Update: bson.M{
+			"$set": bson.M{
+				"abort_requested": true,
+			},
 		},
+		ReturnNew: true,
 	}
-	err := b.db.C("attempts").UpdateId(attemptID, update)
+	query["status"] = "running"
+	_, err = b.db.C("attempts").Find(query).Apply(change, attempt)
 	_, err = b.ShouldRefreshSession(err)
-	return err
+	if err == mgo.ErrNotFound {
+		return nil, ErrAttemptNotRunning
+	} else if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// liveAttempts returns the IDs among attemptIDs of the attempts running with
+// a reservation that did not expire.
+func (b *Base) liveAttempts(attemptIDs []bson.ObjectId, now int64) (map[bson.ObjectId]bool, error) {
+	query := bson.M{
+		"_id":      bson.M{"$in": attemptIDs},
+		"status":   "running",
+		"reserved": bson.M{"$gte": now},
+	}
+	var attempts []Attempt
+	err := b.db.C("attempts").Find(query).Select(bson.M{"_id": 1}).All(&attempts)
+	if _, err = b.ShouldRefreshSession(err); err != nil {
+		return nil, err
+	}
+	live := make(map[bson.ObjectId]bool, len(attempts))
+	for _, attempt := range attempts {
+		live[attempt.ID] = true
+	}
+	return live, nil
 }
 
 // CleanFinishedAttempts cleans attempts that are finished since more than X seconds.
@@ -374,6 +789,16 @@ func (b *Base) EnsureAttemptIndex() (err error) {
 		Sparse:     true,
 	}
 	err = b.db.C("attempts").EnsureIndex(index2)
+	if _, err = b.ShouldRefreshSession(err); err != nil {
+		return
+	}
+	index3 := mgo.Index{
+		Key:        []string{"status", "deleted", "-priority", "reserved"},
+		Unique:     false,
+		Background: true,
+		Sparse:     true,
+	}
+	err = b.db.C("attempts").EnsureIndex(index3)
 	_, err = b.ShouldRefreshSession(err)
 	return
 }
@@ -107,6 +107,47 @@ func (b *Base) migrate() error {
 	if err != nil {
 		return err
 	}
+
+	query = bson.M{
+		"priority": bson.M{"$exists": false},
+		"status":   bson.M{"$in": []string{"pending", "running"}},
+	}
+	update = bson.M{
+		"$set": bson.M{
+			"priority": 0,
+		},
+	}
+	_, err = b.db.C("attempts").UpdateAll(query, update)
+	_, err = b.ShouldRefreshSession(err)
+	if err != nil {
+		return err
+	}
+
+	query = bson.M{
+		"secret": bson.M{"$exists": false},
+	}
+	iter := b.db.C("applications").Find(query).Iter()
+	application := &Application{}
+	for iter.Next(application) {
+		secret, err := newSecret()
+		if err != nil {
+			iter.Close()
+			return err
+		}
+		update = bson.M{
+			"$set": bson.M{
+				"secret": secret,
+			},
+		}
+		if err := b.db.C("applications").UpdateId(application.ID, update); err != nil {
+			_, err = b.ShouldRefreshSession(err)
+			return err
+		}
+	}
+	if err := iter.Close(); err != nil {
+		_, err = b.ShouldRefreshSession(err)
+		return err
+	}
 	return nil
 }
 
@@ -125,6 +166,7 @@ func (b *Base) CleanDeletedRessources() error {
 	attempt := &Attempt{}
 	for iter.Next(attempt) {
 		b.DeQueue(attempt.QueueID, attempt.ID)
+		b.DeHost(attempt)
 		err := b.db.C("attempts").RemoveId(attempt.ID)
 		refreshed, err := b.ShouldRefreshSession(err)
 		if refreshed {
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// CancelTask cancels a Task: its pending attempts are deleted, its retries
+// are aborted and its status becomes `canceled`. The Task and its attempts
+// are kept for auditing. If abort is true the running attempts are asked to
+// abort their request.
+func (b *Base) CancelTask(account bson.ObjectId, application string, name string, abort bool) (task *Task, err error) {
+	query := bson.M{
+		"account":     account,
+		"application": application,
+		"name":        name,
+		"deleted":     false,
+	}
+	now := time.Now()
+	change := mgo.Change{
+		Update: bson.M{
+			"$set": bson.M{
+				"status":          "canceled",
+				"active":          false,
+				"paused":          false,
+				"paused_at":       int64(0),
+				"at":              int64(0),
+				"updated":         now.Unix(),
+				"retry.attempts":  0,
+				"current_attempt": bson.NewObjectId(),
+				"attempt_queued":  false,
+				"attempt_updated": now.UnixNano(),
+			},
+		},
+		ReturnNew: true,
+	}
+	task = &Task{}
+	_, err = b.db.C("tasks").Find(query).Apply(change, task)
+	_, err = b.ShouldRefreshSession(err)
+	if err != nil {
+		task = nil
+		if err == mgo.ErrNotFound {
+			err = nil
+		}
+		return
+	}
+	if _, err = b.DeletePendingAttempts(task.ID); err != nil {
+		return nil, err
+	}
+	if abort {
+		query := bson.M{
+			"task_id": task.ID,
+			"status":  "running",
+			"deleted": false,
+		}
+		update := bson.M{
+			"$set": bson.M{
+				"abort_requested": true,
+			},
+		}
+		_, err = b.db.C("attempts").UpdateAll(query, update)
+		if _, err = b.ShouldRefreshSession(err); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+// finishCanceledAttempt updates the statistics of a canceled Task when one
+// of its attempts finishes, the Task stays canceled.
+func (b *Base) finishCanceledAttempt(task *Task, attempt *Attempt, now time.Time) error {
+	status := attempt.Status
+	errors := 0
+	if status == "error" {
+		errors = 1
+	}
+	// Like the attempts that are not retried, an aborted attempt is an error.
+	if status == "aborted" {
+		status = "error"
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"updated":        now.Unix(),
+			"executed":       now.Unix(),
+			"last_" + status: now.Unix(),
+		},
+		"$inc": bson.M{
+			"executions": 1,
+			"errors":     errors,
+		},
+	}
+	err := b.db.C("tasks").UpdateId(task.ID, update)
+	if _, err = b.ShouldRefreshSession(err); err != nil {
+		return err
+	}
+	return b.AckAttempt(attempt.ID)
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// dueAttempts is signaled when an attempt due now is created in this process.
+var dueAttempts = make(chan bool, 1)
+
+// DueAttempts returns a channel signaled when an attempt due now is created
+// in this process, to wake up the scheduler without waiting for its backoff.
+func DueAttempts() <-chan bool {
+	return dueAttempts
+}
+
+// notifyDueAttempt signals the scheduler of this process if attempt is due now.
+func notifyDueAttempt(attempt *Attempt) {
+	if attempt.Reserved > time.Now().UnixNano() {
+		return
+	}
+	select {
+	case dueAttempts <- true:
+	default:
+		// the scheduler is already signaled
+	}
+}
+
+// NextReserved returns the earliest reserved time in the future of the
+// pending and running attempts in nanoseconds, or 0 if there is none.
+func (b *Base) NextReserved() (int64, error) {
+	query := bson.M{
+		"status":   bson.M{"$in": []string{"pending", "running"}},
+		"reserved": bson.M{"$gt": time.Now().UnixNano()},
+		"deleted":  false,
+	}
+	attempt := &Attempt{}
+	err := b.db.C("attempts").Find(query).Sort("reserved").Select(bson.M{"reserved": 1}).One(attempt)
+	_, err = b.ShouldRefreshSession(err)
+	if err == mgo.ErrNotFound {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	return attempt.Reserved, nil
+}
@@ -0,0 +1,152 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	executorsMu sync.RWMutex
+	executors   = make(map[string]Executor)
+
+	// ErrUnsupportedScheme is returned when no Executor is registered for the scheme of a URL.
+	ErrUnsupportedScheme = errors.New("unsupported URL scheme")
+)
+
+func init() {
+	RegisterExecutor("http", &HTTPExecutor{})
+	RegisterExecutor("https", &HTTPExecutor{})
+	RegisterExecutor("mock", &MockExecutor{})
+	// test:// is kept for backward compatibility.
+	RegisterExecutor("test", &MockExecutor{DefaultDelay: 10 * time.Second})
+}
+
+// ExecutorOptions are the options to execute the request of an Attempt.
+type ExecutorOptions struct {
+	// Timeout is the maximum duration of the request.
+	Timeout time.Duration
+	// MaxResponseBody is the maximum size in bytes of the response body to capture.
+	MaxResponseBody int
+	// MaxResponseHeaders is the maximum number of response headers to capture.
+	MaxResponseHeaders int
+}
+
+// ExecutorResult is the result of the request of an Attempt.
+type ExecutorResult struct {
+	// StatusCode is the HTTP status code, 0 if no response was received.
+	StatusCode int
+	// StatusMessage is a human readable message related to the StatusCode.
+	StatusMessage string
+	// ResponseBody is the beginning of the body of the response.
+	ResponseBody string
+	// ResponseHeaders are the headers of the response.
+	ResponseHeaders map[string]string
+	// RetryAfter is a Unix timestamp in nanoseconds requested by the server.
+	RetryAfter int64
+	// Timing is the timing breakdown of the request.
+	Timing *Timing
+	// Permanent is true when the request can not succeed and must not be retried.
+	Permanent bool
+}
+
+// Executor executes the request of an Attempt, the request must be aborted
+// as soon as the context is done.
+type Executor interface {
+	Execute(ctx context.Context, req *http.Request, options ExecutorOptions) *ExecutorResult
+}
+
+// RegisterExecutor registers the Executor to use for a URL scheme.
+func RegisterExecutor(scheme string, executor Executor) {
+	executorsMu.Lock()
+	defer executorsMu.Unlock()
+	executors[strings.ToLower(scheme)] = executor
+}
+
+// GetExecutor returns the Executor registered for a URL scheme if any.
+func GetExecutor(scheme string) Executor {
+	executorsMu.RLock()
+	defer executorsMu.RUnlock()
+	return executors[strings.ToLower(scheme)]
+}
+
+// HTTPExecutor executes the requests using net/http.
+type HTTPExecutor struct{}
+
+// Execute performs the HTTP request.
+func (e *HTTPExecutor) Execute(ctx context.Context, req *http.Request, options ExecutorOptions) *ExecutorResult {
+	result := &ExecutorResult{}
+	client := &http.Client{
+		Timeout: options.Timeout,
+	}
+	t := newTracer()
+	req = req.WithContext(httptrace.WithClientTrace(ctx, t.ClientTrace()))
+	resp, err := client.Do(req)
+	if err != nil {
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			result.StatusMessage = fmt.Sprintf("request timed out after %s", options.Timeout)
+		} else {
+			result.StatusMessage = err.Error()
+		}
+	} else {
+		defer resp.Body.Close()
+		result.StatusCode = resp.StatusCode
+		result.StatusMessage = resp.Status
+		result.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		result.ResponseHeaders = captureHeaders(resp.Header, options.MaxResponseHeaders)
+		if options.MaxResponseBody > 0 {
+			body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, int64(options.MaxResponseBody)))
+			result.ResponseBody = string(body)
+		}
+	}
+	result.Timing = t.Done()
+	return result
+}
+
+// parseRetryAfter returns the Unix timestamp in nanoseconds of a Retry-After
+// header value expressed either in seconds or as an HTTP date.
+func parseRetryAfter(value string, now time.Time) int64 {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return now.Add(time.Duration(seconds) * time.Second).UnixNano()
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.UnixNano()
+	}
+	return 0
+}
+
+// captureHeaders returns at most max HTTP headers sorted by name.
+func captureHeaders(header http.Header, max int) map[string]string {
+	if max <= 0 || len(header) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if len(keys) > max {
+		keys = keys[:max]
+	}
+	headers := make(map[string]string, len(keys))
+	for _, k := range keys {
+		headers[k] = strings.Join(header[k], ", ")
+	}
+	return headers
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 6, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  int64
+	}{
+		{"", 0},
+		{"120", now.Add(2 * time.Minute).UnixNano()},
+		{"0", now.UnixNano()},
+		{"-1", 0},
+		{"Mon, 01 Jun 2026 10:05:00 GMT", now.Add(5 * time.Minute).UnixNano()},
+		{"Mon, 01 Jun 2026 09:55:00 GMT", 0},
+		{"soon", 0},
+	}
+	for _, test := range tests {
+		if got := parseRetryAfter(test.value, now); got != test.want {
+			t.Errorf("parseRetryAfter(%q) = %d, want %d", test.value, got, test.want)
+		}
+	}
+}
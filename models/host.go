@@ -0,0 +1,148 @@
+package models
+
+import (
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	// saturatedHostDelay is the duration in nanoseconds an attempt waits when its host is saturated.
+	saturatedHostDelay = int64(time.Second)
+)
+
+// Host tracks the attempts of an Account in flight for a target host.
+type Host struct {
+	// ID is the Account ID and the Host.
+	ID string `bson:"_id"`
+
+	// Account is the ID of the Account owning the attempts.
+	Account bson.ObjectId `bson:"account"`
+
+	// Host is the scheme, host and port of the URL of the attempts.
+	Host string `bson:"host"`
+
+	// AttemptsInFlight is the list of attempts currently in flight.
+	AttemptsInFlight []bson.ObjectId `bson:"attempts_in_flight"`
+}
+
+// defaultPorts are the default ports of the URL schemes.
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// hostKey returns the scheme, host and port of an URL, the host is lower case.
+func hostKey(URL string) string {
+	u, err := url.Parse(URL)
+	if err != nil || u.Hostname() == "" {
+		return ""
+	}
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if port == "" {
+		port = defaultPorts[u.Scheme]
+	}
+	if port == "" {
+		if strings.Contains(host, ":") {
+			return u.Scheme + "://[" + host + "]"
+		}
+		return u.Scheme + "://" + host
+	}
+	return u.Scheme + "://" + net.JoinHostPort(host, port)
+}
+
+// EnHost checks if the host of an attempt reached the maximum number of
+// attempts in flight per host of its Account, the limit is the copy kept
+// by the Queue of the attempt.
+func (b *Base) EnHost(attempt *Attempt, queue *Queue) (full bool, err error) {
+	if attempt.Host == "" || queue.MaxInFlightPerHost <= 0 {
+		return false, nil
+	}
+	id := attempt.Account.Hex() + " " + attempt.Host
+	query := bson.M{
+		"_id":                id,
+		"attempts_in_flight": attempt.ID,
+	}
+	nb, err := b.db.C("hosts").Find(query).Count()
+	_, err = b.ShouldRefreshSession(err)
+	if err != nil {
+		return false, err
+	}
+	// this attempt is already in flight
+	if nb == 1 {
+		return false, nil
+	}
+	query = bson.M{
+		"_id":                id,
+		"attempts_in_flight": bson.M{"$ne": attempt.ID},
+		"attempts_in_flight." + strconv.Itoa(queue.MaxInFlightPerHost-1): bson.M{"$exists": false},
+	}
+	update := bson.M{
+		"$set":  bson.M{"account": attempt.Account, "host": attempt.Host},
+		"$push": bson.M{"attempts_in_flight": attempt.ID},
+	}
+	_, err = b.db.C("hosts").Upsert(query, update)
+	_, err = b.ShouldRefreshSession(err)
+	if mgo.IsDup(err) {
+		// the host is saturated
+		return true, nil
+	}
+	return false, err
+}
+
+// DeHost removes an attempt from the attempts in flight of its host.
+func (b *Base) DeHost(attempt *Attempt) (err error) {
+	if attempt.Host == "" {
+		return nil
+	}
+	query := bson.M{
+		"_id":                attempt.Account.Hex() + " " + attempt.Host,
+		"attempts_in_flight": attempt.ID,
+	}
+	update := bson.M{
+		"$pull": bson.M{"attempts_in_flight": attempt.ID},
+	}
+	err = b.db.C("hosts").Update(query, update)
+	_, err = b.ShouldRefreshSession(err)
+	if err == mgo.ErrNotFound {
+		err = nil
+	}
+	return
+}
+
+// fixHosts removes from the hosts the attempts that are no longer running
+// with a live reservation and returns the number of attempts removed.
+func (b *Base) fixHosts(now int64) (fixed int, err error) {
+	var hosts []Host
+	query := bson.M{
+		"attempts_in_flight.0": bson.M{"$exists": true},
+	}
+	err = b.db.C("hosts").Find(query).All(&hosts)
+	if _, err = b.ShouldRefreshSession(err); err != nil {
+		return
+	}
+	for _, host := range hosts {
+		live, err := b.liveAttempts(host.AttemptsInFlight, now)
+		if err != nil {
+			return fixed, err
+		}
+		for _, attemptID := range host.AttemptsInFlight {
+			if live[attemptID] {
+				continue
+			}
+			log.Printf("Fixing host %s: releasing the slot of attempt %s\n", host.ID, attemptID.Hex())
+			if err := b.DeHost(&Attempt{ID: attemptID, Account: host.Account, Host: host.Host}); err != nil {
+				return fixed, err
+			}
+			fixed++
+		}
+	}
+	return
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Instance identifies this hookyd process as the owner of the Leases.
+var Instance = instanceName()
+
+// Lease grants a maintenance job to a single hookyd instance until it expires.
+type Lease struct {
+	// ID is the name of the job.
+	ID string `bson:"_id"`
+
+	// Owner is the instance holding the Lease.
+	Owner string `bson:"owner"`
+
+	// Acquired is a Unix timestamp representing the time the owner acquired the Lease.
+	Acquired int64 `bson:"acquired"`
+
+	// Expires is a Unix timestamp representing the time the Lease expires if not renewed.
+	Expires int64 `bson:"expires"`
+}
+
+// instanceName returns the host name and the process ID of this instance.
+func instanceName() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s:%d", hostname, os.Getpid())
+}
+
+// AcquireLease acquires or renews the Lease of a job for owner, it fails
+// when another owner holds a Lease that did not expire.
+func (b *Base) AcquireLease(name string, owner string, ttl time.Duration) (acquired bool, err error) {
+	now := time.Now()
+	query := bson.M{
+		"_id":   name,
+		"owner": owner,
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"expires": now.Add(ttl).Unix(),
+		},
+	}
+	err = b.db.C("leases").Update(query, update)
+	_, err = b.ShouldRefreshSession(err)
+	if err == nil {
+		return true, nil
+	} else if err != mgo.ErrNotFound {
+		return false, err
+	}
+	query = bson.M{
+		"_id":     name,
+		"expires": bson.M{"$lte": now.Unix()},
+	}
+	update = bson.M{
+		"$set": bson.M{
+			"owner":    owner,
+			"acquired": now.Unix(),
+			"expires":  now.Add(ttl).Unix(),
+		},
+	}
+	_, err = b.db.C("leases").Upsert(query, update)
+	_, err = b.ShouldRefreshSession(err)
+	if mgo.IsDup(err) {
+		// the lease is held by another owner
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ReleaseLease releases the Lease of a job if owner holds it.
+func (b *Base) ReleaseLease(name string, owner string) (err error) {
+	query := bson.M{
+		"_id":   name,
+		"owner": owner,
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"expires": 0,
+		},
+	}
+	err = b.db.C("leases").Update(query, update)
+	_, err = b.ShouldRefreshSession(err)
+	if err == mgo.ErrNotFound {
+		err = nil
+	}
+	return
+}
+
+// GetLeases returns the Leases of the maintenance jobs.
+func (b *Base) GetLeases() (leases []Lease, err error) {
+	err = b.db.C("leases").Find(nil).Sort("_id").All(&leases)
+	_, err = b.ShouldRefreshSession(err)
+	return
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// MockExecutor is a fake Executor configured by the query string of the URL:
+//
+//	mock://?delay=2s&status=503&body=unavailable&retry_after=30
+//
+// The default status is 200 and an attempt lasting longer than its timeout
+// fails as a real request would.
+type MockExecutor struct {
+	// DefaultDelay is the delay used when the URL does not define one.
+	DefaultDelay time.Duration
+}
+
+// Execute simulates the request.
+func (e *MockExecutor) Execute(ctx context.Context, req *http.Request, options ExecutorOptions) *ExecutorResult {
+	q := req.URL.Query()
+	delay := e.DefaultDelay
+	if value := q.Get("delay"); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			delay = d
+		}
+	}
+	statusCode := http.StatusOK
+	if value := q.Get("status"); value != "" {
+		if code, err := strconv.Atoi(value); err == nil {
+			statusCode = code
+		}
+	}
+	ModelsAttemptDebug("Mock attempt %s starting", req.URL)
+	start := time.Now()
+	timedOut := options.Timeout > 0 && delay > options.Timeout
+	if timedOut {
+		delay = options.Timeout
+	}
+	select {
+	case <-ctx.Done():
+		return &ExecutorResult{
+			StatusMessage: ctx.Err().Error(),
+			Timing:        &Timing{Total: sinceMilliseconds(start)},
+		}
+	case <-time.After(delay):
+	}
+	if timedOut {
+		return &ExecutorResult{
+			StatusMessage: fmt.Sprintf("request timed out after %s", options.Timeout),
+			Timing:        &Timing{Total: sinceMilliseconds(start)},
+		}
+	}
+	result := &ExecutorResult{
+		StatusCode:    statusCode,
+		StatusMessage: fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		Timing:        &Timing{Total: sinceMilliseconds(start), FirstByte: sinceMilliseconds(start)},
+	}
+	if body := q.Get("body"); options.MaxResponseBody > 0 {
+		if len(body) > options.MaxResponseBody {
+			body = body[:options.MaxResponseBody]
+		}
+		result.ResponseBody = body
+	}
+	if value := q.Get("retry_after"); value != "" {
+		result.RetryAfter = parseRetryAfter(value, time.Now())
+	}
+	ModelsAttemptDebug("Mock attempt %s done", req.URL)
+	return result
+}
@@ -0,0 +1,245 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	// maxSkippedRuns is the maximum number of skipped runs recorded at once.
+	maxSkippedRuns = 100
+)
+
+var (
+	// ErrInvalidOverlap is returned when an overlap policy is unknown.
+	ErrInvalidOverlap = errors.New("invalid overlap policy")
+)
+
+// OverlapPolicies are the policies applied to the runs of a schedule starting
+// while the previous attempt is still running: `forbid` skips them, `queue`
+// runs one right after the previous attempt and `allow` runs them in parallel.
+var OverlapPolicies = map[string]bool{
+	"forbid": true,
+	"queue":  true,
+	"allow":  true,
+}
+
+// runsCount returns the number of runs of a Task counted against MaxRuns,
+// with the `allow` overlap policy the runs still running are counted too.
+func (t *Task) runsCount() int {
+	if t.StartedRuns > t.Runs {
+		return t.StartedRuns
+	}
+	return t.Runs
+}
+
+// scheduleParallelRun creates the attempt of the next run of a Task using the
+// `allow` overlap policy as soon as its current attempt starts.
+func (b *Base) scheduleParallelRun(attempt *Attempt) error {
+	if attempt.ScheduledAt == 0 {
+		return nil
+	}
+	task, err := b.GetTaskByID(attempt.TaskID)
+	if task == nil || err != nil {
+		return err
+	}
+	if task.Overlap != "allow" || task.CurrentAttempt != attempt.ID || !task.Active || task.Schedule == "" {
+		return nil
+	}
+	// The run of a retried attempt was counted when it first started.
+	if task.Retry != nil && task.Retry.Attempts > 0 {
+		return nil
+	}
+	query := bson.M{
+		"_id":             task.ID,
+		"current_attempt": attempt.ID,
+	}
+	// The run of this attempt is counted along with the next one.
+	var at int64
+	if task.MaxRuns == 0 || task.StartedRuns+1 < task.MaxRuns {
+		if at, err = nextRun(task.Schedule, task.Timezone, task.StartAt, task.EndAt, time.Now()); err != nil {
+			return err
+		}
+	}
+	if at == 0 {
+		// No run is left: this attempt stays the current attempt of the Task.
+		err = b.db.C("tasks").Update(query, bson.M{"$inc": bson.M{"started_runs": 1}})
+		if _, err = b.ShouldRefreshSession(err); err == mgo.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+	if task.MaxRuns > 0 {
+		query["started_runs"] = bson.M{"$lt": task.MaxRuns - 1}
+	}
+	change := mgo.Change{
+		Update: bson.M{
+			"$set": bson.M{
+				"at":              at + task.jitterOffset(),
+				"scheduled_at":    at,
+				"current_attempt": bson.NewObjectId(),
+				"attempt_queued":  false,
+				"attempt_updated": time.Now().UnixNano(),
+			},
+			"$inc": bson.M{
+				"started_runs": 1,
+			},
+		},
+		ReturnNew: true,
+	}
+	_, err = b.db.C("tasks").Find(query).Apply(change, task)
+	_, err = b.ShouldRefreshSession(err)
+	if err == mgo.ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	_, err = b.NewAttempt(task, false, false)
+	return err
+}
+
+// finishParallelAttempt updates the statistics of a Task using the `allow`
+// overlap policy when an attempt that is no more its current attempt
+// finishes, and retries it in a new attempt if needed.
+func (b *Base) finishParallelAttempt(task *Task, attempt *Attempt, now time.Time) (retryAttempt *Attempt, err error) {
+	status := attempt.Status
+	errors := 0
+	var at int64
+	retries := attempt.Retries
+	if status == "aborted" && !attempt.abortRequested() {
+		// An attempt aborted by a shutdown is retried right away without
+		// using the retry budget.
+		at = now.UnixNano()
+	} else if status == "error" || status == "aborted" {
+		if status == "error" {
+			errors = 1
+		}
+		retry := *task.Retry
+		retry.Attempts = attempt.Retries
+		if !attempt.Permanent && !retry.IsPermanent(int(attempt.StatusCode)) && !attempt.abortRequested() {
+			if next, err := retry.NextAttempt(now.UnixNano()); err == nil {
+				at = next
+				if attempt.RetryAfter > 0 {
+					at = retry.RetryAfter(now.UnixNano(), attempt.RetryAfter)
+				}
+				retries = retry.Attempts
+			}
+		}
+	}
+	if at > 0 {
+		retryAttempt = &Attempt{}
+		*retryAttempt = *attempt
+		retryAttempt.ID = bson.NewObjectId()
+		retryAttempt.Reserved = at
+		retryAttempt.At = at
+		retryAttempt.Started = 0
+		retryAttempt.AbortRequested = false
+		retryAttempt.Finished = 0
+		retryAttempt.Status = "pending"
+		retryAttempt.StatusCode = 0
+		retryAttempt.StatusMessage = ""
+		retryAttempt.ResponseBody = ""
+		retryAttempt.ResponseHeaders = nil
+		retryAttempt.Timing = nil
+		retryAttempt.RetryAfter = 0
+		retryAttempt.Retries = retries
+		retryAttempt.Acked = false
+		if err := b.db.C("attempts").Insert(retryAttempt); err != nil {
+			_, err = b.ShouldRefreshSession(err)
+			return nil, err
+		}
+		status = "retrying"
+	}
+	// An aborted attempt that is not retried leaves the task in error.
+	if status == "aborted" {
+		status = "error"
+	}
+	runs := 1
+	taskStatus := status
+	if status == "retrying" {
+		runs = 0
+	} else if status != "error" && (task.At == 0 || task.MaxRuns > 0 && task.Runs+runs >= task.MaxRuns) {
+		// No run is left once the last attempt of the Task finished.
+		running, err := b.hasOtherAttempts(task, attempt)
+		if err != nil {
+			return nil, err
+		}
+		if !running {
+			taskStatus = "completed"
+		}
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"status":         taskStatus,
+			"updated":        now.Unix(),
+			"executed":       now.Unix(),
+			"last_" + status: now.Unix(),
+		},
+		"$inc": bson.M{
+			"executions": 1,
+			"errors":     errors,
+			"runs":       runs,
+		},
+	}
+	err = b.db.C("tasks").UpdateId(task.ID, update)
+	if _, err = b.ShouldRefreshSession(err); err != nil {
+		return nil, err
+	}
+	if err = b.AckAttempt(attempt.ID); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// hasOtherAttempts reports whether a Task has other attempts than a given
+// one still running or waiting for their retry.
+func (b *Base) hasOtherAttempts(task *Task, attempt *Attempt) (bool, error) {
+	query := bson.M{
+		"task_id": task.ID,
+		"_id":     bson.M{"$ne": attempt.ID},
+		"status":  bson.M{"$in": []string{"pending", "running"}},
+		"deleted": false,
+	}
+	nb, err := b.db.C("attempts").Find(query).Count()
+	if _, err = b.ShouldRefreshSession(err); err != nil {
+		return false, err
+	}
+	return nb > 0, nil
+}
+
+// recordSkippedRuns adds a `skipped` attempt for each run of a Task skipped
+// because it overlapped the previous attempt.
+func (b *Base) recordSkippedRuns(task *Task, skipped []int64) error {
+	now := time.Now()
+	for _, scheduledAt := range skipped {
+		attempt := &Attempt{
+			ID:            bson.NewObjectId(),
+			TaskID:        task.ID,
+			Account:       task.Account,
+			Application:   task.Application,
+			Queue:         task.Queue,
+			QueueID:       task.QueueID,
+			Task:          task.Name,
+			URL:           task.URL,
+			HTTPAuth:      task.HTTPAuth,
+			Method:        task.Method,
+			Headers:       task.Headers,
+			Payload:       task.Payload,
+			At:            scheduledAt,
+			ScheduledAt:   scheduledAt,
+			Finished:      now.Unix(),
+			Priority:      task.Priority,
+			Status:        "skipped",
+			StatusMessage: "previous attempt still running",
+			Acked:         true,
+		}
+		if err := b.db.C("attempts").Insert(attempt); err != nil {
+			_, err = b.ShouldRefreshSession(err)
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,362 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	// ErrInvalidMissed is returned when a missed policy is unknown.
+	ErrInvalidMissed = errors.New("invalid missed policy")
+	// ErrTaskNotPaused is returned when resuming a Task that is inactive but was not paused.
+	ErrTaskNotPaused = errors.New("task is not paused")
+)
+
+// MissedPolicies are the policies applied to the overdue attempts when a
+// Queue or an Application is resumed: `run_all` executes them and `skip`
+// skips the runs of the schedules missed during the pause.
+var MissedPolicies = map[string]bool{
+	"run_all": true,
+	"skip":    true,
+}
+
+// setPaused pauses or resumes the item of a collection and returns it.
+func (b *Base) setPaused(collection string, query bson.M, paused bool, result interface{}) (err error) {
+	update := bson.M{
+		"paused":    paused,
+		"paused_at": int64(0),
+	}
+	if paused {
+		update["paused_at"] = time.Now().Unix()
+	}
+	change := mgo.Change{
+		Update: bson.M{
+			"$set": update,
+		},
+		ReturnNew: true,
+	}
+	q := bson.M{"paused": bson.M{"$ne": paused}}
+	for k, v := range query {
+		q[k] = v
+	}
+	_, err = b.db.C(collection).Find(q).Apply(change, result)
+	_, err = b.ShouldRefreshSession(err)
+	if err == mgo.ErrNotFound {
+		// Already paused or resumed.
+		err = b.db.C(collection).Find(query).One(result)
+		_, err = b.ShouldRefreshSession(err)
+	}
+	return
+}
+
+// PauseQueue stops the dispatch of the attempts of a Queue.
+func (b *Base) PauseQueue(account bson.ObjectId, application string, name string) (queue *Queue, err error) {
+	query := bson.M{
+		"account":     account,
+		"application": application,
+		"name":        name,
+		"deleted":     false,
+	}
+	queue = &Queue{}
+	if err = b.setPaused("queues", query, true, queue); err != nil {
+		queue = nil
+		if err == mgo.ErrNotFound {
+			err = ErrQueueNotFound
+		}
+	}
+	return
+}
+
+// ResumeQueue resumes the dispatch of the attempts of a Queue.
+func (b *Base) ResumeQueue(account bson.ObjectId, application string, name string, missed string) (queue *Queue, err error) {
+	if missed == "" {
+		missed = "run_all"
+	}
+	if _, ok := MissedPolicies[missed]; !ok {
+		return nil, ErrInvalidMissed
+	}
+	if queue, err = b.GetQueue(account, application, name); err != nil {
+		return
+	}
+	if queue.Paused && missed == "skip" {
+		if err = b.skipMissedRuns(bson.M{"queue_id": queue.ID}, "missed while the queue was paused"); err != nil {
+			return nil, err
+		}
+	}
+	query := bson.M{
+		"_id": queue.ID,
+	}
+	if err = b.setPaused("queues", query, false, queue); err != nil {
+		queue = nil
+	}
+	return
+}
+
+// PauseApplication stops the dispatch of the attempts of an Application.
+func (b *Base) PauseApplication(account bson.ObjectId, name string) (application *Application, err error) {
+	query := bson.M{
+		"account": account,
+		"name":    name,
+		"deleted": false,
+	}
+	application = &Application{}
+	if err = b.setPaused("applications", query, true, application); err != nil {
+		application = nil
+		if err == mgo.ErrNotFound {
+			err = nil
+		}
+	}
+	return
+}
+
+// ResumeApplication resumes the dispatch of the attempts of an Application.
+func (b *Base) ResumeApplication(account bson.ObjectId, name string, missed string) (application *Application, err error) {
+	if missed == "" {
+		missed = "run_all"
+	}
+	if _, ok := MissedPolicies[missed]; !ok {
+		return nil, ErrInvalidMissed
+	}
+	application, err = b.GetApplication(account, name)
+	if application == nil || err != nil {
+		return
+	}
+	if application.Paused && missed == "skip" {
+		query := bson.M{
+			"account":     account,
+			"application": name,
+		}
+		if err = b.skipMissedRuns(query, "missed while the application was paused"); err != nil {
+			return nil, err
+		}
+	}
+	query := bson.M{
+		"_id": application.ID,
+	}
+	if err = b.setPaused("applications", query, false, application); err != nil {
+		application = nil
+	}
+	return
+}
+
+// pausedRessources returns the IDs of the paused Queues and the paused Applications.
+func (b *Base) pausedRessources() (queues []bson.ObjectId, applications []bson.M, err error) {
+	query := bson.M{
+		"paused":  true,
+		"deleted": false,
+	}
+	var pausedQueues []Queue
+	if err = b.db.C("queues").Find(query).Select(bson.M{"_id": 1}).All(&pausedQueues); err != nil {
+		_, err = b.ShouldRefreshSession(err)
+		return
+	}
+	for _, queue := range pausedQueues {
+		queues = append(queues, queue.ID)
+	}
+	var pausedApplications []Application
+	if err = b.db.C("applications").Find(query).Select(bson.M{"account": 1, "name": 1}).All(&pausedApplications); err != nil {
+		_, err = b.ShouldRefreshSession(err)
+		return
+	}
+	for _, application := range pausedApplications {
+		applications = append(applications, bson.M{"account": application.Account, "application": application.Name})
+	}
+	return
+}
+
+// skipMissedRuns skips the overdue attempts of the runs of the schedules and
+// moves their Tasks to their next run.
+func (b *Base) skipMissedRuns(query bson.M, reason string) error {
+	now := time.Now()
+	q := bson.M{
+		"status":       "pending",
+		"reserved":     bson.M{"$lt": now.UnixNano()},
+		"scheduled_at": bson.M{"$gt": 0},
+		"deleted":      false,
+	}
+	for k, v := range query {
+		q[k] = v
+	}
+	iter := b.db.C("attempts").Find(q).Iter()
+	attempt := &Attempt{}
+	for iter.Next(attempt) {
+		update := bson.M{
+			"$set": bson.M{
+				"status":         "skipped",
+				"status_message": reason,
+				"finished":       now.Unix(),
+				"acked":          true,
+			},
+		}
+		if err := b.db.C("attempts").UpdateId(attempt.ID, update); err != nil {
+			_, err = b.ShouldRefreshSession(err)
+			iter.Close()
+			return err
+		}
+		if err := b.skipToNextRun(attempt, now); err != nil {
+			iter.Close()
+			return err
+		}
+	}
+	if err := iter.Close(); err != nil {
+		_, err = b.ShouldRefreshSession(err)
+		return err
+	}
+	return nil
+}
+
+// skipToNextRun moves a Task whose current attempt was skipped to the next run of its Schedule.
+func (b *Base) skipToNextRun(attempt *Attempt, now time.Time) error {
+	task, err := b.GetTaskByID(attempt.TaskID)
+	if task == nil || err != nil || task.CurrentAttempt != attempt.ID || task.Schedule == "" {
+		return err
+	}
+	scheduledAt, err := nextRun(task.Schedule, task.Timezone, task.StartAt, task.EndAt, now)
+	if err != nil {
+		return err
+	}
+	update := bson.M{
+		"at":              int64(0),
+		"scheduled_at":    scheduledAt,
+		"misfires":        0,
+		"active":          false,
+		"current_attempt": bson.NewObjectId(),
+		"attempt_queued":  false,
+		"attempt_updated": now.UnixNano(),
+	}
+	if scheduledAt > 0 {
+		update["at"] = scheduledAt + task.jitterOffset()
+		update["active"] = true
+	} else {
+		// The validity window of the schedule is closed.
+		update["status"] = "completed"
+	}
+	change := mgo.Change{
+		Update: bson.M{
+			"$set": update,
+			"$inc": bson.M{
+				"skipped":        1,
+				"retry.attempts": -task.Retry.Attempts,
+			},
+		},
+		ReturnNew: true,
+	}
+	query := bson.M{
+		"_id":             task.ID,
+		"current_attempt": attempt.ID,
+	}
+	_, err = b.db.C("tasks").Find(query).Apply(change, task)
+	_, err = b.ShouldRefreshSession(err)
+	if err == mgo.ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	_, err = b.NewAttempt(task, false, false)
+	return err
+}
+
+// PauseTask deactivates an active Task without changing its retry state,
+// its pending attempt is deleted.
+func (b *Base) PauseTask(account bson.ObjectId, application string, name string) (task *Task, err error) {
+	query := bson.M{
+		"account":     account,
+		"application": application,
+		"name":        name,
+		"deleted":     false,
+		"active":      true,
+	}
+	now := time.Now().Unix()
+	change := mgo.Change{
+		Update: bson.M{
+			"$set": bson.M{
+				"active":    false,
+				"paused":    true,
+				"paused_at": now,
+				"updated":   now,
+			},
+		},
+		ReturnNew: true,
+	}
+	task = &Task{}
+	_, err = b.db.C("tasks").Find(query).Apply(change, task)
+	_, err = b.ShouldRefreshSession(err)
+	if err == mgo.ErrNotFound {
+		// Already paused or inactive.
+		return b.GetTask(account, application, name)
+	} else if err != nil {
+		return nil, err
+	}
+	if _, err = b.DeletePendingAttempts(task.ID); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// ResumeTask reactivates a paused Task: the next attempt of a scheduled
+// Task is computed from its Schedule, a one-off Task keeps its time. The
+// Tasks deactivated for another reason, canceled or completed, are not
+// resumed.
+func (b *Base) ResumeTask(account bson.ObjectId, application string, name string) (task *Task, err error) {
+	task, err = b.GetTask(account, application, name)
+	if task == nil || err != nil || task.Active {
+		return
+	}
+	if !task.Paused {
+		return nil, ErrTaskNotPaused
+	}
+	now := time.Now()
+	update := bson.M{
+		"paused":          false,
+		"paused_at":       int64(0),
+		"updated":         now.Unix(),
+		"current_attempt": bson.NewObjectId(),
+		"attempt_queued":  false,
+		"attempt_updated": now.UnixNano(),
+	}
+	at := task.At
+	if task.Schedule != "" {
+		var scheduledAt int64
+		if task.MaxRuns == 0 || task.runsCount() < task.MaxRuns {
+			if scheduledAt, err = nextRun(task.Schedule, task.Timezone, task.StartAt, task.EndAt, now); err != nil {
+				return nil, err
+			}
+		}
+		at = 0
+		if scheduledAt > 0 {
+			at = scheduledAt + task.jitterOffset()
+		} else {
+			// The window closed or MaxRuns was reached during the pause.
+			update["status"] = "completed"
+		}
+		update["scheduled_at"] = scheduledAt
+		update["misfires"] = 0
+	}
+	update["at"] = at
+	update["active"] = at > 0
+	change := mgo.Change{
+		Update: bson.M{
+			"$set": update,
+		},
+		ReturnNew: true,
+	}
+	query := bson.M{
+		"_id":    task.ID,
+		"paused": true,
+	}
+	_, err = b.db.C("tasks").Find(query).Apply(change, task)
+	_, err = b.ShouldRefreshSession(err)
+	if err == mgo.ErrNotFound {
+		// Already resumed.
+		return b.GetTaskByID(task.ID)
+	} else if err != nil {
+		return nil, err
+	}
+	if _, err = b.NewAttempt(task, true, false); err != nil {
+		return nil, err
+	}
+	return
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// AgeAttempts raises by one the priority of the pending attempts waiting
+// for longer than the priority aging of their Queue, so that low priority
+// attempts are not starved by a constant flow of higher priority ones.
+func (b *Base) AgeAttempts() error {
+	query := bson.M{
+		"priority_aging": bson.M{"$gt": 0},
+		"deleted":        false,
+	}
+	iter := b.db.C("queues").Find(query).Iter()
+	queue := &Queue{}
+	for iter.Next(queue) {
+		now := time.Now().UnixNano()
+		limit := now - int64(queue.PriorityAging)*1000000000
+		query := bson.M{
+			"queue_id": queue.ID,
+			"status":   "pending",
+			"reserved": bson.M{"$lt": limit},
+			"aged":     bson.M{"$not": bson.M{"$gte": limit}},
+			"deleted":  false,
+		}
+		update := bson.M{
+			"$inc": bson.M{"priority": 1},
+			"$set": bson.M{"aged": now},
+		}
+		info, err := b.db.C("attempts").UpdateAll(query, update)
+		if _, err = b.ShouldRefreshSession(err); err != nil {
+			iter.Close()
+			return err
+		}
+		if info.Updated > 0 {
+			ModelsAttemptDebug("Aged %d attempts in queue %s", info.Updated, queue.ID.Hex())
+		}
+	}
+	if err := iter.Close(); err != nil {
+		_, err = b.ShouldRefreshSession(err)
+		return err
+	}
+	return nil
+}
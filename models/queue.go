@@ -2,6 +2,9 @@ package models
 
 import (
 	"errors"
+	"log"
+	"math"
+	"time"
 
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
@@ -10,6 +13,15 @@ import (
 const (
 	// DefaultMaxInFlight is the maximum number of tasks executed in parallel.
 	DefaultMaxInFlight = 10
+
+	// DefaultTimeout is the maximum duration of an attempt in seconds.
+	DefaultTimeout = 60
+
+	// DefaultMaxResponseBody is the maximum size in bytes of a response body stored with an attempt.
+	DefaultMaxResponseBody = 4096
+
+	// DefaultMaxResponseHeaders is the maximum number of response headers stored with an attempt.
+	DefaultMaxResponseHeaders = 50
 )
 
 var (
@@ -17,6 +29,10 @@ var (
 	ErrDeleteDefaultQueue = errors.New("can not delete default queue")
 	// ErrQueueNotFound is returned when the queue does not exist.
 	ErrQueueNotFound = errors.New("queue does not exist")
+	// ErrInvalidPriorityAging is returned when a priority aging is negative.
+	ErrInvalidPriorityAging = errors.New("invalid priority aging")
+	// ErrInvalidTimeout is returned when a timeout is negative.
+	ErrInvalidTimeout = errors.New("invalid timeout")
 )
 
 // Queue ...
@@ -47,10 +63,81 @@ type Queue struct {
 
 	// AttemptsInFlight is the list of attempts currently in flight.
 	AttemptsInFlight []bson.ObjectId `bson:"attempts_in_flight"`
+
+	// Timeout is the default maximum duration of an attempt in seconds.
+	Timeout int `bson:"timeout,omitempty"`
+
+	// Success is the default list of HTTP status codes considered as a success.
+	Success string `bson:"success,omitempty"`
+
+	// MaxResponseBody is the maximum size in bytes of a response body stored
+	// with an attempt, a negative value disables the capture.
+	MaxResponseBody int `bson:"max_response_body,omitempty"`
+
+	// MaxResponseHeaders is the maximum number of response headers stored
+	// with an attempt, a negative value disables the capture.
+	MaxResponseHeaders int `bson:"max_response_headers,omitempty"`
+
+	// Jitter is the default maximum delay in seconds added to the runs of the schedules.
+	Jitter int `bson:"jitter,omitempty"`
+
+	// PriorityAging is the duration in seconds after which the priority of a
+	// pending attempt is raised by one, 0 disables the aging.
+	PriorityAging int `bson:"priority_aging,omitempty"`
+
+	// RateLimit limits the number of attempts started per second if any.
+	RateLimit *RateLimit `bson:"rate_limit,omitempty"`
+
+	// Tokens is the number of tokens in the bucket of the RateLimit.
+	Tokens float64 `bson:"tokens"`
+
+	// TokensUpdated is a Unix timestamp in nanoseconds of the last update of Tokens.
+	TokensUpdated int64 `bson:"tokens_updated"`
+
+	// Paused is true when the dispatch of the attempts is paused.
+	Paused bool `bson:"paused"`
+
+	// PausedAt is a Unix timestamp of the time the Queue was paused.
+	PausedAt int64 `bson:"paused_at,omitempty"`
+
+	// MaxInFlightPerHost is a copy of the maximum number of attempts in
+	// flight per host of the Account, it is updated with the Account.
+	MaxInFlightPerHost int `bson:"max_in_flight_per_host,omitempty"`
+}
+
+// QueueOptions are the parameters of a Queue given to NewQueue, the zero
+// values select the defaults of hooky.
+type QueueOptions struct {
+	// Retry is the retry strategy parameters in case of errors.
+	Retry *Retry
+
+	// MaxInFlight is the maximum number of attempts executed in parallel.
+	MaxInFlight int
+
+	// Timeout is the maximum duration of an attempt in seconds.
+	Timeout int
+
+	// Success are the HTTP status codes considered as a success.
+	Success string
+
+	// MaxResponseBody is the maximum size in bytes of a response body stored with an attempt.
+	MaxResponseBody int
+
+	// MaxResponseHeaders is the maximum number of response headers stored with an attempt.
+	MaxResponseHeaders int
+
+	// Jitter is the default maximum delay in seconds added to the runs of the schedules.
+	Jitter int
+
+	// PriorityAging is the duration in seconds after which a pending attempt gains a priority.
+	PriorityAging int
+
+	// RateLimit limits the number of attempts started per second if any.
+	RateLimit *RateLimit
 }
 
 // NewQueue creates a new Queue.
-func (b *Base) NewQueue(account bson.ObjectId, applicationName string, name string, retry *Retry, maxInFlight int) (queue *Queue, err error) {
+func (b *Base) NewQueue(account bson.ObjectId, applicationName string, name string, options QueueOptions) (queue *Queue, err error) {
 	application, err := b.GetApplication(account, applicationName)
 	if application == nil {
 		return nil, ErrApplicationNotFound
@@ -58,24 +145,81 @@ func (b *Base) NewQueue(account bson.ObjectId, applicationName string, name stri
 	if err != nil {
 		return
 	}
+	owner, err := b.GetAccount(account)
+	if err != nil {
+		return
+	}
+	var maxInFlightPerHost int
+	if owner != nil {
+		maxInFlightPerHost = owner.MaxInFlightPerHost
+	}
 	// Define default parameters for our retry strategy.
-	if retry == nil {
-		retry = &Retry{}
+	if options.Retry == nil {
+		options.Retry = &Retry{}
+	}
+	options.Retry.SetDefault()
+	if err = options.Retry.Validate(); err != nil {
+		return nil, err
 	}
-	retry.SetDefault()
 	// Define default parameter for maxInFlight.
-	if maxInFlight == 0 {
-		maxInFlight = DefaultMaxInFlight
+	if options.MaxInFlight == 0 {
+		options.MaxInFlight = DefaultMaxInFlight
+	}
+	// Define default parameter for timeout.
+	if options.Timeout == 0 {
+		options.Timeout = DefaultTimeout
+	}
+	if options.Timeout < 0 {
+		return nil, ErrInvalidTimeout
+	}
+	// Define default parameter for success.
+	if options.Success == "" {
+		options.Success = DefaultSuccess
+	}
+	if _, err = ParseStatusCodes(options.Success); err != nil {
+		return nil, err
+	}
+	// Define default parameters for the capture of the responses.
+	if options.MaxResponseBody == 0 {
+		options.MaxResponseBody = DefaultMaxResponseBody
+	}
+	if options.MaxResponseHeaders == 0 {
+		options.MaxResponseHeaders = DefaultMaxResponseHeaders
+	}
+	if options.Jitter < 0 {
+		return nil, ErrInvalidJitter
+	}
+	if options.PriorityAging < 0 {
+		return nil, ErrInvalidPriorityAging
+	}
+	// The bucket of the rate limit starts full.
+	var tokens float64
+	if options.RateLimit != nil {
+		options.RateLimit.SetDefault()
+		if err = options.RateLimit.Validate(); err != nil {
+			return nil, err
+		}
+		tokens = float64(options.RateLimit.Burst)
 	}
 
 	queue = &Queue{
-		ID:                bson.NewObjectId(),
-		Account:           account,
-		Application:       applicationName,
-		Name:              name,
-		Retry:             retry,
-		MaxInFlight:       maxInFlight,
-		AvailableInFlight: maxInFlight,
+		ID:                 bson.NewObjectId(),
+		Account:            account,
+		Application:        applicationName,
+		Name:               name,
+		Retry:              options.Retry,
+		MaxInFlight:        options.MaxInFlight,
+		AvailableInFlight:  options.MaxInFlight,
+		Timeout:            options.Timeout,
+		Success:            options.Success,
+		MaxResponseBody:    options.MaxResponseBody,
+		MaxResponseHeaders: options.MaxResponseHeaders,
+		Jitter:             options.Jitter,
+		PriorityAging:      options.PriorityAging,
+		RateLimit:          options.RateLimit,
+		Tokens:             tokens,
+		TokensUpdated:      time.Now().UnixNano(),
+		MaxInFlightPerHost: maxInFlightPerHost,
 	}
 	err = b.db.C("queues").Insert(queue)
 	_, err = b.ShouldRefreshSession(err)
@@ -89,12 +233,31 @@ func (b *Base) NewQueue(account bson.ObjectId, applicationName string, name stri
 			_, err = b.ShouldRefreshSession(err)
 			return nil, err
 		}
-		incMaxInFlight := maxInFlight - queue.MaxInFlight
+		incMaxInFlight := options.MaxInFlight - queue.MaxInFlight
+		update := bson.M{
+			"retry":                  options.Retry,
+			"timeout":                options.Timeout,
+			"success":                options.Success,
+			"max_response_body":      options.MaxResponseBody,
+			"max_response_headers":   options.MaxResponseHeaders,
+			"jitter":                 options.Jitter,
+			"priority_aging":         options.PriorityAging,
+			"rate_limit":             options.RateLimit,
+			"max_in_flight_per_host": maxInFlightPerHost,
+		}
+		// The bucket keeps its tokens unless the rate limit changed, they are
+		// then limited to the new burst.
+		if !sameRateLimit(queue.RateLimit, options.RateLimit) && options.RateLimit != nil {
+			now := time.Now().UnixNano()
+			if queue.RateLimit != nil {
+				tokens = math.Min(queue.RateLimit.refill(queue.Tokens, queue.TokensUpdated, now), tokens)
+			}
+			update["tokens"] = tokens
+			update["tokens_updated"] = now
+		}
 		change := mgo.Change{
 			Update: bson.M{
-				"$set": bson.M{
-					"retry": retry,
-				},
+				"$set": update,
 				"$inc": bson.M{
 					"max_in_flight":       incMaxInFlight,
 					"available_in_flight": incMaxInFlight,
@@ -130,6 +293,20 @@ func (b *Base) GetQueue(account bson.ObjectId, application string, name string)
 	return
 }
 
+// GetQueueByID returns a Queue given its ID.
+func (b *Base) GetQueueByID(queueID bson.ObjectId) (queue *Queue, err error) {
+	queue = &Queue{}
+	err = b.db.C("queues").FindId(queueID).One(queue)
+	_, err = b.ShouldRefreshSession(err)
+	if err != nil {
+		queue = nil
+		if err == mgo.ErrNotFound {
+			err = nil
+		}
+	}
+	return
+}
+
 // GetQueues returns a list of Queues.
 func (b *Base) GetQueues(account bson.ObjectId, application string, lp ListParams, lr *ListResult) (err error) {
 	query := bson.M{
@@ -212,40 +389,46 @@ func (b *Base) DeleteQueues(account bson.ObjectId, application string) (err erro
 	return
 }
 
-// EnQueue checks if a queue reached its max_in_flight.
-func (b *Base) EnQueue(queueID bson.ObjectId, attemptID bson.ObjectId) (full bool, err error) {
+// EnQueue checks if a queue reached its max_in_flight, the Queue is
+// returned without its attempts in flight when the attempt takes a slot.
+func (b *Base) EnQueue(queueID bson.ObjectId, attemptID bson.ObjectId) (queue *Queue, full bool, err error) {
+	fields := bson.M{"attempts_in_flight": 0}
 	query := bson.M{
 		"_id":                queueID,
 		"attempts_in_flight": attemptID,
 	}
-	nb, err := b.db.C("queues").Find(query).Count()
+	queue = &Queue{}
+	err = b.db.C("queues").Find(query).Select(fields).One(queue)
 	_, err = b.ShouldRefreshSession(err)
-	if err != nil && err != mgo.ErrNotFound {
-		return false, err
+	if err == nil {
+		// this attemptID is already in the queue
+		return queue, false, nil
 	}
-	// this attemptID is already in the queue
-	if nb == 1 {
-		return false, nil
+	if err != mgo.ErrNotFound {
+		return nil, false, err
 	}
 	query = bson.M{
-		"_id": queueID,
+		"_id":                 queueID,
 		"available_in_flight": bson.M{"$gt": 0},
 		"attempts_in_flight":  bson.M{"$ne": attemptID},
 	}
-	update := bson.M{
-		"$inc":  bson.M{"available_in_flight": -1},
-		"$push": bson.M{"attempts_in_flight": attemptID},
+	change := mgo.Change{
+		Update: bson.M{
+			"$inc":  bson.M{"available_in_flight": -1},
+			"$push": bson.M{"attempts_in_flight": attemptID},
+		},
+		ReturnNew: true,
 	}
-	if err := b.db.C("queues").Update(query, update); err != nil {
+	if _, err := b.db.C("queues").Find(query).Select(fields).Apply(change, queue); err != nil {
 		_, err := b.ShouldRefreshSession(err)
 		if err == mgo.ErrNotFound {
 			// the queue is full
-			return true, nil
+			return nil, true, nil
 		}
-		return false, err
+		return nil, false, err
 	}
 	// the queue is not full
-	return false, nil
+	return queue, false, nil
 }
 
 // DeQueue increases the available_in_flight by one.
@@ -266,9 +449,61 @@ func (b *Base) DeQueue(queueID bson.ObjectId, attemptID bson.ObjectId) (err erro
 	return
 }
 
-// FixQueues fixes unconsistencies with available_in_flight.
-func (b *Base) FixQueues() (err error) {
-	return
+// FixQueues releases the slots of the attempts in flight that are no longer
+// running with a live reservation, after a crash between EnQueue and DeQueue,
+// and restores available_in_flight to max_in_flight minus the attempts in flight.
+// It returns the number of corrections.
+func (b *Base) FixQueues() (fixed int, err error) {
+	now := time.Now().UnixNano()
+	var queues []Queue
+	err = b.db.C("queues").Find(nil).All(&queues)
+	if _, err = b.ShouldRefreshSession(err); err != nil {
+		return
+	}
+	for _, queue := range queues {
+		if len(queue.AttemptsInFlight) > 0 {
+			live, err := b.liveAttempts(queue.AttemptsInFlight, now)
+			if err != nil {
+				return fixed, err
+			}
+			for _, attemptID := range queue.AttemptsInFlight {
+				if live[attemptID] {
+					continue
+				}
+				log.Printf("Fixing queue %s: releasing the slot of attempt %s\n", queue.ID.Hex(), attemptID.Hex())
+				if err := b.DeQueue(queue.ID, attemptID); err != nil {
+					return fixed, err
+				}
+				fixed++
+			}
+		}
+		// EnQueue, DeQueue and UpdateQueue keep the difference between
+		// max_in_flight and the slots in use, so the drift can be added
+		// back without racing with them.
+		current := &Queue{}
+		err = b.db.C("queues").FindId(queue.ID).One(current)
+		_, err = b.ShouldRefreshSession(err)
+		if err == mgo.ErrNotFound {
+			continue
+		} else if err != nil {
+			return fixed, err
+		}
+		drift := current.MaxInFlight - len(current.AttemptsInFlight) - current.AvailableInFlight
+		if drift != 0 {
+			log.Printf("Fixing queue %s: available_in_flight is %d instead of %d\n", queue.ID.Hex(), current.AvailableInFlight, current.AvailableInFlight+drift)
+			update := bson.M{
+				"$inc": bson.M{"available_in_flight": drift},
+			}
+			err = b.db.C("queues").UpdateId(queue.ID, update)
+			if _, err = b.ShouldRefreshSession(err); err != nil && err != mgo.ErrNotFound {
+				return fixed, err
+			}
+			fixed++
+		}
+	}
+
+	hosts, err := b.fixHosts(now)
+	return fixed + hosts, err
 }
 
 // EnsureQueueIndex creates mongo indexes for Queue.
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	// ErrInvalidRateLimit is returned when a rate limit is invalid.
+	ErrInvalidRateLimit = errors.New("invalid rate limit")
+)
+
+// RateLimit limits the number of attempts started by a Queue using a token
+// bucket shared by all the hookyd instances.
+type RateLimit struct {
+	// Rate is the number of attempts allowed per Period.
+	Rate float64 `bson:"rate" json:"rate"`
+	// Period is the duration in seconds of the Rate, 1 by default.
+	Period int `bson:"period" json:"period"`
+	// Burst is the maximum number of attempts started at once, the Rate rounded up by default.
+	Burst int `bson:"burst" json:"burst"`
+}
+
+// SetDefault sets the default values of a RateLimit.
+func (r *RateLimit) SetDefault() {
+	if r.Period == 0 {
+		r.Period = 1
+	}
+	if r.Burst == 0 {
+		r.Burst = int(math.Ceil(r.Rate))
+	}
+}
+
+// Validate checks the parameters of a RateLimit.
+func (r *RateLimit) Validate() error {
+	if r.Rate <= 0 || r.Period <= 0 || r.Burst <= 0 {
+		return ErrInvalidRateLimit
+	}
+	return nil
+}
+
+// sameRateLimit reports whether two rate limits are equal.
+func sameRateLimit(a *RateLimit, b *RateLimit) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// refill returns the number of tokens in a bucket at a given time.
+func (r *RateLimit) refill(tokens float64, updated int64, now int64) float64 {
+	if now > updated {
+		tokens += float64(now-updated) * r.Rate / float64(int64(r.Period)*1000000000)
+	}
+	return math.Min(tokens, float64(r.Burst))
+}
+
+// wait returns the duration in nanoseconds until a token is available.
+func (r *RateLimit) wait(tokens float64) int64 {
+	return int64((1 - tokens) * float64(int64(r.Period)*1000000000) / r.Rate)
+}
+
+// AvailableTokens returns the number of tokens available in the bucket of a Queue.
+func (q *Queue) AvailableTokens() float64 {
+	if q.RateLimit == nil {
+		return 0
+	}
+	return q.RateLimit.refill(q.Tokens, q.TokensUpdated, time.Now().UnixNano())
+}
+
+// TakeToken takes a token from the bucket of a Queue, if the Queue is
+// throttled it returns the duration in nanoseconds until a token is available.
+// The Queues without a rate limit are never throttled.
+func (b *Base) TakeToken(queue *Queue) (throttled bool, wait int64, err error) {
+	queueID := queue.ID
+	// The bucket is updated only if nobody else updated it in the meantime.
+	for queue.RateLimit != nil {
+		now := time.Now().UnixNano()
+		tokens := queue.RateLimit.refill(queue.Tokens, queue.TokensUpdated, now)
+		if tokens < 1 {
+			return true, queue.RateLimit.wait(tokens), nil
+		}
+		query := bson.M{
+			"_id":            queueID,
+			"tokens_updated": queue.TokensUpdated,
+		}
+		update := bson.M{
+			"$set": bson.M{
+				"tokens":         tokens - 1,
+				"tokens_updated": now,
+			},
+		}
+		err = b.db.C("queues").Update(query, update)
+		_, err = b.ShouldRefreshSession(err)
+		if err != mgo.ErrNotFound {
+			return
+		}
+		queue = &Queue{}
+		if err = b.db.C("queues").FindId(queueID).Select(bson.M{"attempts_in_flight": 0}).One(queue); err != nil {
+			_, err = b.ShouldRefreshSession(err)
+			if err == mgo.ErrNotFound {
+				err = nil
+			}
+			return
+		}
+	}
+	return
+}
+
+// ReleaseAttempt puts back a reserved attempt in the pending attempts until a given time.
+func (b *Base) ReleaseAttempt(attemptID bson.ObjectId, until int64) error {
+	update := bson.M{
+		"$set": bson.M{
+			"status":   "pending",
+			"reserved": until,
+		},
+		"$unset": bson.M{
+			"started": "",
+		},
+	}
+	err := b.db.C("attempts").UpdateId(attemptID, update)
+	_, err = b.ShouldRefreshSession(err)
+	return err
+}
@@ -0,0 +1,44 @@
+package models
+
+import "testing"
+
+func TestRateLimitRefill(t *testing.T) {
+	r := &RateLimit{Rate: 10, Period: 1, Burst: 5}
+	second := int64(1000000000)
+	tests := []struct {
+		tokens  float64
+		elapsed int64
+		want    float64
+	}{
+		{0, 0, 0},
+		{0, second / 10, 1},
+		{1.5, second / 5, 3.5},
+		{0, 10 * second, 5},
+		{4, -second, 4},
+	}
+	for _, test := range tests {
+		if got := r.refill(test.tokens, second, second+test.elapsed); got != test.want {
+			t.Errorf("refill(%v, %d) = %v, want %v", test.tokens, test.elapsed, got, test.want)
+		}
+	}
+}
+
+func TestSameRateLimit(t *testing.T) {
+	a := &RateLimit{Rate: 10, Period: 1, Burst: 5}
+	tests := []struct {
+		b    *RateLimit
+		want bool
+	}{
+		{&RateLimit{Rate: 10, Period: 1, Burst: 5}, true},
+		{&RateLimit{Rate: 10, Period: 1, Burst: 10}, false},
+		{nil, false},
+	}
+	for _, test := range tests {
+		if got := sameRateLimit(a, test.b); got != test.want {
+			t.Errorf("sameRateLimit(%v, %v) = %v, want %v", a, test.b, got, test.want)
+		}
+	}
+	if !sameRateLimit(nil, nil) {
+		t.Error("sameRateLimit(nil, nil) = false, want true")
+	}
+}
@@ -28,6 +28,11 @@ type Retry struct {
 	Min int `bson:"min" json:"min"`
 	// Max is the maximum duration between each attempts in seconds.
 	Max int `bson:"max" json:"max"`
+	// Permanent is the list of HTTP status codes that must not be retried.
+	Permanent string `bson:"permanent,omitempty" json:"permanent,omitempty"`
+	// Retryable is the list of HTTP status codes that can be retried, if set
+	// any other error status code is considered as permanent.
+	Retryable string `bson:"retryable,omitempty" json:"retryable,omitempty"`
 }
 
 func (r *Retry) NextAttempt(now int64) (int64, error) {
@@ -50,6 +55,51 @@ func (r *Retry) NextAttempt(now int64) (int64, error) {
 	return now + int64(next*1000000000), nil
 }
 
+// RetryAfter returns the time of the next attempt requested by the server
+// with a Retry-After header, capped by the maximum duration between attempts.
+func (r *Retry) RetryAfter(now int64, retryAfter int64) int64 {
+	max := now + int64(r.Max)*1000000000
+	if retryAfter > max {
+		return max
+	}
+	return retryAfter
+}
+
+// IsPermanent returns true if an attempt that failed with this HTTP status
+// code must not be retried.
+func (r *Retry) IsPermanent(statusCode int) bool {
+	// Network errors and timeouts are always retried.
+	if statusCode == 0 {
+		return false
+	}
+	if r.Permanent != "" {
+		if codes, err := ParseStatusCodes(r.Permanent); err == nil && codes.Match(statusCode) {
+			return true
+		}
+	}
+	if r.Retryable != "" {
+		if codes, err := ParseStatusCodes(r.Retryable); err == nil && !codes.Match(statusCode) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the lists of status codes.
+func (r *Retry) Validate() error {
+	if r.Permanent != "" {
+		if _, err := ParseStatusCodes(r.Permanent); err != nil {
+			return err
+		}
+	}
+	if r.Retryable != "" {
+		if _, err := ParseStatusCodes(r.Retryable); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (r *Retry) SetDefault() {
 	if r.MaxAttempts == 0 {
 		r.MaxAttempts = 10
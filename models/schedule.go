@@ -0,0 +1,275 @@
+package models
+
+import (
+	"errors"
+	"hash/fnv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	// DefaultMisfireLimit is the maximum number of missed runs replayed with the `fire_all` misfire policy.
+	DefaultMisfireLimit = 10
+)
+
+var (
+	// ErrInvalidTimezone is returned when a timezone is not a valid IANA name.
+	ErrInvalidTimezone = errors.New("invalid timezone")
+	// ErrInvalidMisfire is returned when a misfire policy is unknown.
+	ErrInvalidMisfire = errors.New("invalid misfire policy")
+	// ErrInvalidSchedule is returned when a cron schedule is empty.
+	ErrInvalidSchedule = errors.New("invalid schedule")
+	// ErrInvalidJitter is returned when a jitter is negative.
+	ErrInvalidJitter = errors.New("invalid jitter")
+)
+
+// MisfirePolicies are the policies applied to the runs of a schedule missed while hooky was down:
+// `skip` ignores them, `fire_once` runs a single catch-up for the first missed run and `fire_all`
+// replays each missed run up to a limit.
+var MisfirePolicies = map[string]bool{
+	"skip":      true,
+	"fire_once": true,
+	"fire_all":  true,
+}
+
+// loadLocation returns the Location of an IANA timezone name, UTC if empty.
+func loadLocation(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, ErrInvalidTimezone
+	}
+	return loc, nil
+}
+
+// wallClock returns the UTC time showing the same wall clock as t.
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// localTime returns the first instant showing the wall clock w in loc. It
+// returns false if this wall clock does not exist in loc.
+func localTime(w time.Time, loc *time.Location) (time.Time, bool) {
+	t := time.Date(w.Year(), w.Month(), w.Day(), w.Hour(), w.Minute(), w.Second(), w.Nanosecond(), loc)
+	if !wallClock(t).Equal(w) {
+		return t, false
+	}
+	// When the clocks are set back the wall clock exists twice.
+	_, offset := t.Zone()
+	_, offsetBefore := t.Add(-3 * time.Hour).Zone()
+	if offsetBefore > offset {
+		first := t.Add(-time.Duration(offsetBefore-offset) * time.Second)
+		if wallClock(first).Equal(w) {
+			return first, true
+		}
+	}
+	return t, true
+}
+
+// nextRun returns the Unix timestamp in nanoseconds of the first run after a
+// given time of a cron schedule evaluated in a timezone. Around DST changes the schedule follows
+// the wall clock: runs falling in a skipped period (clocks set forward) are
+// skipped, and runs falling in a repeated period (clocks set back) are
+// executed once, at their first occurrence. Runs before startAt or after
+// endAt, when defined, are ignored and 0 is returned once endAt is reached.
+func nextRun(schedule string, timezone string, startAt int64, endAt int64, after time.Time) (int64, error) {
+	if startAt > 0 && after.UnixNano() < startAt {
+		after = time.Unix(0, startAt-1)
+	}
+	sched, err := cron.Parse(schedule)
+	if err != nil {
+		return 0, err
+	}
+	loc, err := loadLocation(timezone)
+	if err != nil {
+		return 0, err
+	}
+	w := wallClock(after.In(loc))
+	for {
+		w = sched.Next(w)
+		if w.IsZero() {
+			return 0, nil
+		}
+		if t, ok := localTime(w, loc); ok && t.After(after) {
+			if endAt > 0 && t.UnixNano() > endAt {
+				return 0, nil
+			}
+			return t.UnixNano(), nil
+		}
+	}
+}
+
+// JitterSeed returns the seed of the jitter of a Task, it is based on the
+// identity of the Task so that the offset is stable when the Task is updated.
+func JitterSeed(account bson.ObjectId, application string, name string) string {
+	return account.Hex() + "/" + application + "/" + name
+}
+
+// jitterOffset returns a deterministic offset in nanoseconds between 0 and a
+// jitter in seconds for a given seed.
+func jitterOffset(seed string, jitter int) int64 {
+	if jitter <= 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	h.Write([]byte(seed))
+	return int64(h.Sum64() % uint64(jitter*1000000000))
+}
+
+// jitterOffset returns the offset in nanoseconds applied to the runs of the Schedule of a Task.
+func (t *Task) jitterOffset() int64 {
+	return jitterOffset(JitterSeed(t.Account, t.Application, t.Name), t.Jitter)
+}
+
+// scheduledRun is the next run of a scheduled Task.
+type scheduledRun struct {
+	// at is the time of the next attempt.
+	at int64
+	// scheduledAt is the time of the run of the schedule the attempt belongs to.
+	scheduledAt int64
+	// misfires is the number of consecutive catch-up runs.
+	misfires int
+	// skipped are the times of the runs skipped because they overlapped the last attempt.
+	skipped []int64
+}
+
+// nextScheduledRun returns the next run of a scheduled Task given its last attempt.
+func (t *Task) nextScheduledRun(last *Attempt, now time.Time) (run scheduledRun, err error) {
+	if t.MaxRuns > 0 && t.runsCount() >= t.MaxRuns {
+		return
+	}
+	if last.ScheduledAt > 0 {
+		var missed int64
+		if missed, err = nextRun(t.Schedule, t.Timezone, t.StartAt, t.EndAt, time.Unix(0, last.ScheduledAt)); err != nil {
+			return
+		}
+		if missed > 0 && missed <= now.UnixNano() {
+			if last.Started > 0 && missed >= last.Started {
+				// The run started while the last attempt was running.
+				switch t.Overlap {
+				case "queue":
+					return scheduledRun{at: now.UnixNano(), scheduledAt: missed}, nil
+				case "", "forbid":
+					for missed > 0 && missed <= now.UnixNano() && len(run.skipped) < maxSkippedRuns {
+						run.skipped = append(run.skipped, missed)
+						if missed, err = nextRun(t.Schedule, t.Timezone, t.StartAt, t.EndAt, time.Unix(0, missed)); err != nil {
+							return
+						}
+					}
+				}
+			} else if t.Misfire == "fire_once" || t.Misfire == "fire_all" {
+				// The run was missed while hooky was down.
+				limit := 1
+				if t.Misfire == "fire_all" {
+					limit = t.MisfireLimit
+					if limit == 0 {
+						limit = DefaultMisfireLimit
+					}
+				}
+				if t.Misfires < limit {
+					return scheduledRun{at: now.UnixNano(), scheduledAt: missed, misfires: t.Misfires + 1}, nil
+				}
+			}
+		}
+	}
+	run.scheduledAt, err = nextRun(t.Schedule, t.Timezone, t.StartAt, t.EndAt, now)
+	if run.scheduledAt > 0 {
+		run.at = run.scheduledAt + t.jitterOffset()
+	}
+	return
+}
+
+// scheduleDescriptors are the cron descriptors and their normalized form.
+var scheduleDescriptors = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+// NormalizeSchedule validates a cron schedule and returns its normalized
+// form: descriptors are expanded and the 6 fields are separated by a single
+// space.
+func NormalizeSchedule(schedule string) (string, error) {
+	schedule = strings.TrimSpace(schedule)
+	if schedule == "" {
+		return "", ErrInvalidSchedule
+	}
+	if _, err := cron.Parse(schedule); err != nil {
+		return "", err
+	}
+	if normalized, ok := scheduleDescriptors[schedule]; ok {
+		return normalized, nil
+	}
+	if strings.HasPrefix(schedule, "@every ") {
+		d, _ := time.ParseDuration(schedule[len("@every "):])
+		return "@every " + d.String(), nil
+	}
+	fields := strings.Fields(schedule)
+	if len(fields) == 5 {
+		fields = append(fields, "*")
+	}
+	return strings.Join(fields, " "), nil
+}
+
+// PreviewSchedule returns the normalized form of a cron schedule and the
+// Unix timestamps in nanoseconds of its next runs after a given time. The
+// runs are delayed by the jitter offset of the given seed if any.
+func PreviewSchedule(schedule string, timezone string, startAt int64, endAt int64, jitter int, seed string, after time.Time, count int) (normalized string, runs []int64, err error) {
+	if normalized, err = NormalizeSchedule(schedule); err != nil {
+		return
+	}
+	if _, err = loadLocation(timezone); err != nil {
+		return
+	}
+	if jitter < 0 {
+		return "", nil, ErrInvalidJitter
+	}
+	var offset int64
+	if seed != "" {
+		offset = jitterOffset(seed, jitter)
+	}
+	runs = []int64{}
+	for len(runs) < count {
+		var next int64
+		if next, err = nextRun(normalized, timezone, startAt, endAt, after); err != nil || next == 0 {
+			return
+		}
+		runs = append(runs, next+offset)
+		after = time.Unix(0, next)
+	}
+	return
+}
+
+// NextRuns returns the Unix timestamps in nanoseconds of the next attempts
+// of the Schedule of a Task.
+func (t *Task) NextRuns(count int) ([]int64, error) {
+	runs := []int64{}
+	if !t.Active || t.Schedule == "" {
+		return runs, nil
+	}
+	if t.MaxRuns > 0 && t.MaxRuns-t.runsCount() < count {
+		count = t.MaxRuns - t.runsCount()
+	}
+	// The next attempt may be a retry or a catch-up run.
+	after := time.Now()
+	if t.At > 0 && count > 0 {
+		runs = append(runs, t.At)
+		if t.ScheduledAt > 0 {
+			after = time.Unix(0, t.ScheduledAt)
+		}
+	}
+	_, next, err := PreviewSchedule(t.Schedule, t.Timezone, t.StartAt, t.EndAt, t.Jitter, JitterSeed(t.Account, t.Application, t.Name), after, count-len(runs))
+	if err != nil {
+		return nil, err
+	}
+	return append(runs, next...), nil
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextRun(t *testing.T) {
+	date := func(value string) time.Time {
+		d, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	tests := []struct {
+		name     string
+		schedule string
+		timezone string
+		startAt  string
+		endAt    string
+		after    string
+		want     string
+	}{
+		{"utc", "0 0 * * * *", "", "", "", "2026-06-01T10:30:00Z", "2026-06-01T11:00:00Z"},
+		{"timezone", "0 0 9 * * *", "Europe/Paris", "", "", "2026-06-01T10:00:00Z", "2026-06-02T07:00:00Z"},
+		// Europe/Paris sets its clocks forward from 02:00 to 03:00 on 2026-03-29.
+		{"skipped daily run", "0 30 2 * * *", "Europe/Paris", "", "", "2026-03-28T12:00:00Z", "2026-03-30T00:30:00Z"},
+		{"skipped hourly run", "0 0 * * * *", "Europe/Paris", "", "", "2026-03-29T00:30:00Z", "2026-03-29T01:00:00Z"},
+		// Europe/Paris sets its clocks back from 03:00 to 02:00 on 2026-10-25.
+		{"repeated daily run", "0 30 2 * * *", "Europe/Paris", "", "", "2026-10-24T12:00:00Z", "2026-10-25T00:30:00Z"},
+		{"repeated daily run once", "0 30 2 * * *", "Europe/Paris", "", "", "2026-10-25T00:30:00Z", "2026-10-26T01:30:00Z"},
+		{"repeated hourly run once", "0 0 * * * *", "Europe/Paris", "", "", "2026-10-25T00:30:00Z", "2026-10-25T02:00:00Z"},
+		{"before start", "0 0 * * * *", "", "2026-06-01T12:00:00Z", "", "2026-06-01T10:30:00Z", "2026-06-01T12:00:00Z"},
+		{"at end", "0 0 * * * *", "", "", "2026-06-01T11:00:00Z", "2026-06-01T10:30:00Z", "2026-06-01T11:00:00Z"},
+		{"after end", "0 0 * * * *", "", "", "2026-06-01T10:59:00Z", "2026-06-01T10:30:00Z", ""},
+	}
+	for _, test := range tests {
+		var startAt, endAt int64
+		if test.startAt != "" {
+			startAt = date(test.startAt).UnixNano()
+		}
+		if test.endAt != "" {
+			endAt = date(test.endAt).UnixNano()
+		}
+		var want int64
+		if test.want != "" {
+			want = date(test.want).UnixNano()
+		}
+		got, err := nextRun(test.schedule, test.timezone, startAt, endAt, date(test.after))
+		if err != nil {
+			t.Errorf("%s: nextRun returned %s", test.name, err)
+		} else if got != want {
+			t.Errorf("%s: nextRun = %s, want %s", test.name, time.Unix(0, got).UTC(), time.Unix(0, want).UTC())
+		}
+	}
+}
+
+func TestNextRunInvalidTimezone(t *testing.T) {
+	if _, err := nextRun("0 0 * * * *", "Mars/Olympus", 0, 0, time.Now()); err != ErrInvalidTimezone {
+		t.Errorf("nextRun returned %v, want %s", err, ErrInvalidTimezone)
+	}
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// SignatureHeader is the HTTP header carrying the signature of a request.
+	SignatureHeader = "X-Hooky-Signature"
+)
+
+var (
+	// ErrInvalidSignatureHeader is returned when the signature header can not be parsed.
+	ErrInvalidSignatureHeader = errors.New("invalid signature header")
+)
+
+// newSecret returns a random hex encoded signing secret.
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Signature returns the hex encoded HMAC-SHA256 of a request signed with secret.
+func Signature(secret string, method string, url string, timestamp int64, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s\n%s\n%d\n%s", method, url, timestamp, body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignatureHeaderValue returns the value of the SignatureHeader for a request
+// signed with each of the secrets: `t=<timestamp>,v1=<signature>[,v1=<signature>]`.
+func SignatureHeaderValue(secrets []string, method string, url string, timestamp int64, body string) string {
+	parts := []string{"t=" + strconv.FormatInt(timestamp, 10)}
+	for _, secret := range secrets {
+		parts = append(parts, "v1="+Signature(secret, method, url, timestamp, body))
+	}
+	return strings.Join(parts, ",")
+}
+
+// ParseSignatureHeader returns the timestamp and the signatures of a SignatureHeader value.
+func ParseSignatureHeader(value string) (timestamp int64, signatures []string, err error) {
+	for _, part := range strings.Split(value, ",") {
+		p := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(p) != 2 {
+			return 0, nil, ErrInvalidSignatureHeader
+		}
+		switch p[0] {
+		case "t":
+			if timestamp, err = strconv.ParseInt(p[1], 10, 64); err != nil {
+				return 0, nil, ErrInvalidSignatureHeader
+			}
+		case "v1":
+			signatures = append(signatures, p[1])
+		}
+	}
+	if timestamp == 0 || len(signatures) == 0 {
+		return 0, nil, ErrInvalidSignatureHeader
+	}
+	return
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultSuccess is the list of HTTP status codes considered as a success.
+	DefaultSuccess = "200"
+)
+
+var (
+	// ErrInvalidStatusCodes is returned when a list of HTTP status codes can not be parsed.
+	ErrInvalidStatusCodes = errors.New("invalid list of status codes")
+)
+
+// statusCodeRange is an inclusive range of HTTP status codes.
+type statusCodeRange struct {
+	min int
+	max int
+}
+
+// StatusCodes is a list of HTTP status codes ranges.
+type StatusCodes []statusCodeRange
+
+// ParseStatusCodes parses a comma separated list of HTTP status codes,
+// each item being either a code (`200`), a range (`200-299`) or a class (`2xx`).
+func ParseStatusCodes(spec string) (StatusCodes, error) {
+	var codes StatusCodes
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		var r statusCodeRange
+		var err error
+		if len(item) == 3 && strings.HasSuffix(strings.ToLower(item), "xx") {
+			var class int
+			if class, err = strconv.Atoi(item[:1]); err != nil {
+				return nil, ErrInvalidStatusCodes
+			}
+			r = statusCodeRange{class * 100, class*100 + 99}
+		} else if p := strings.SplitN(item, "-", 2); len(p) == 2 {
+			if r.min, err = strconv.Atoi(strings.TrimSpace(p[0])); err != nil {
+				return nil, ErrInvalidStatusCodes
+			}
+			if r.max, err = strconv.Atoi(strings.TrimSpace(p[1])); err != nil {
+				return nil, ErrInvalidStatusCodes
+			}
+		} else {
+			if r.min, err = strconv.Atoi(item); err != nil {
+				return nil, ErrInvalidStatusCodes
+			}
+			r.max = r.min
+		}
+		if r.min < 100 || r.max > 599 || r.min > r.max {
+			return nil, ErrInvalidStatusCodes
+		}
+		codes = append(codes, r)
+	}
+	if len(codes) == 0 {
+		return nil, ErrInvalidStatusCodes
+	}
+	return codes, nil
+}
+
+// Match returns true if the HTTP status code is part of the list.
+func (s StatusCodes) Match(code int) bool {
+	for _, r := range s {
+		if code >= r.min && code <= r.max {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package models
+
+import "testing"
+
+func TestParseStatusCodes(t *testing.T) {
+	tests := []struct {
+		spec    string
+		match   []int
+		nomatch []int
+	}{
+		{"200", []int{200}, []int{201, 204}},
+		{"2xx", []int{200, 250, 299}, []int{199, 300}},
+		{"2XX", []int{204}, []int{304}},
+		{"200-299,304", []int{200, 299, 304}, []int{300, 303, 305}},
+		{" 200 , 204 - 206 ,", []int{200, 204, 206}, []int{201, 207}},
+	}
+	for _, test := range tests {
+		codes, err := ParseStatusCodes(test.spec)
+		if err != nil {
+			t.Errorf("ParseStatusCodes(%q) returned %s", test.spec, err)
+			continue
+		}
+		for _, code := range test.match {
+			if !codes.Match(code) {
+				t.Errorf("ParseStatusCodes(%q) does not match %d", test.spec, code)
+			}
+		}
+		for _, code := range test.nomatch {
+			if codes.Match(code) {
+				t.Errorf("ParseStatusCodes(%q) matches %d", test.spec, code)
+			}
+		}
+	}
+}
+
+func TestParseStatusCodesInvalid(t *testing.T) {
+	for _, spec := range []string{"", ",", "abc", "axx", "2x", "99", "600", "300-200", "200-", "6xx"} {
+		if _, err := ParseStatusCodes(spec); err != ErrInvalidStatusCodes {
+			t.Errorf("ParseStatusCodes(%q) returned %v, want %s", spec, err, ErrInvalidStatusCodes)
+		}
+	}
+}
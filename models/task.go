@@ -1,10 +1,11 @@
 package models
 
 import (
+	"errors"
 	"log"
+	"net/url"
 	"time"
 
-	"github.com/robfig/cron"
 	"github.com/tj/go-debug"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
@@ -13,15 +14,25 @@ import (
 var (
 	// ModelsTaskDebug ...
 	ModelsTaskDebug = debug.Debug("hooky.models.task")
+
+	// ErrAtInPast is returned when a Task is scheduled in the past.
+	ErrAtInPast = errors.New("at is in the past")
+
+	// ErrInvalidWindow is returned when the end of the validity window of a Task is before its start.
+	ErrInvalidWindow = errors.New("endAt is before startAt")
+
+	// ErrNoRunInWindow is returned when the schedule of a Task has no run left in its validity window.
+	ErrNoRunInWindow = errors.New("no run of the schedule left between startAt and endAt")
 )
 
 // TaskStatuses are the differents statuses that a Task can have.
 var TaskStatuses = map[string]bool{
-	"pending":  true,
-	"retrying": true,
-	"canceled": true,
-	"success":  true,
-	"error":    true,
+	"pending":   true,
+	"retrying":  true,
+	"canceled":  true,
+	"success":   true,
+	"error":     true,
+	"completed": true,
 }
 
 // Task describes a Task.
@@ -62,10 +73,54 @@ type Task struct {
 	// Schedule is a cron specification describing the recurrency if any.
 	Schedule string `bson:"schedule"`
 
+	// Timezone is the IANA name of the timezone used to evaluate the Schedule.
+	Timezone string `bson:"timezone,omitempty"`
+
+	// Misfire is the policy for the runs missed while hooky was down: `skip`, `fire_once` or `fire_all`.
+	Misfire string `bson:"misfire,omitempty"`
+
+	// MisfireLimit is the maximum number of missed runs replayed with the `fire_all` policy.
+	MisfireLimit int `bson:"misfire_limit,omitempty"`
+
+	// Misfires counts the consecutive catch-up runs.
+	Misfires int `bson:"misfires,omitempty"`
+
+	// ScheduledAt is a Unix timestamp in nanoseconds of the run of the Schedule the current attempt belongs to.
+	ScheduledAt int64 `bson:"scheduled_at,omitempty"`
+
+	// Overlap is the policy for the runs starting while the previous attempt is still running: `forbid`, `queue` or `allow`.
+	Overlap string `bson:"overlap,omitempty"`
+
+	// Skipped counts the runs skipped because they overlapped the previous attempt.
+	Skipped int `bson:"skipped,omitempty"`
+
+	// StartAt is a Unix timestamp in nanoseconds before which the Schedule has no run.
+	StartAt int64 `bson:"start_at,omitempty"`
+
+	// EndAt is a Unix timestamp in nanoseconds after which the Schedule has no run.
+	EndAt int64 `bson:"end_at,omitempty"`
+
+	// MaxRuns is the maximum number of runs of the Schedule.
+	MaxRuns int `bson:"max_runs,omitempty"`
+
+	// Runs counts the runs of the Schedule that were executed.
+	Runs int `bson:"runs,omitempty"`
+
+	// StartedRuns counts the runs of the Schedule that started with the
+	// `allow` overlap policy, including the ones still running.
+	StartedRuns int `bson:"started_runs,omitempty"`
+
+	// Priority is the priority of the attempts, higher priorities are executed first.
+	Priority int `bson:"priority,omitempty"`
+
+	// Jitter is the maximum delay in seconds added to the runs of the Schedule,
+	// the delay is random but stable for a given Task.
+	Jitter int `bson:"jitter,omitempty"`
+
 	// At is a Unix timestamp representing the next time a request must be performed.
 	At int64 `bson:"at"`
 
-	// Status is either `pending`, `retrying`, `canceled`, `success` or `error`
+	// Status is either `pending`, `retrying`, `canceled`, `success`, `error` or `completed`
 	Status string `bson:"status"`
 
 	// Executed is the timestamp of the last time a attempt was executed.
@@ -74,6 +129,12 @@ type Task struct {
 	// Active is the task active.
 	Active bool `bson:"active"`
 
+	// Paused is true when the Task was deactivated by a pause.
+	Paused bool `bson:"paused"`
+
+	// PausedAt is a Unix timestamp of the time the Task was paused.
+	PausedAt int64 `bson:"paused_at,omitempty"`
+
 	// Errors counts the number of attempts that failed.
 	Errors int `bson:"errors,omitempty"`
 
@@ -89,6 +150,12 @@ type Task struct {
 	// Retry is the retry strategy parameters in case of errors.
 	Retry *Retry `bson:"retry"`
 
+	// Timeout is the maximum duration of an attempt in seconds.
+	Timeout int `bson:"timeout,omitempty"`
+
+	// Success is the list of HTTP status codes considered as a success.
+	Success string `bson:"success,omitempty"`
+
 	// CurrentAttempt is the current attempt ID for this task.
 	CurrentAttempt bson.ObjectId `bson:"current_attempt"`
 
@@ -110,16 +177,75 @@ func (h *Task) ErrorRate() int {
 	return int(h.Errors * 100 / h.Executions)
 }
 
-func nextRun(schedule string) (int64, error) {
-	sched, err := cron.Parse(schedule)
-	if err != nil {
-		return 0, err
-	}
-	return sched.Next(time.Now().UTC()).UnixNano(), nil
+// TaskOptions are the parameters of a Task given to NewTask, the zero
+// values select the defaults of the Queue or of hooky.
+type TaskOptions struct {
+	// Queue is the name of the Queue of the Task, `default` by default.
+	Queue string
+
+	// URL is the URL that the worker with requests.
+	URL string
+
+	// HTTPAuth is the HTTP authentication to use if any.
+	HTTPAuth HTTPAuth
+
+	// Method is the HTTP method of the request, POST by default.
+	Method string
+
+	// Headers are the HTTP headers of the request.
+	Headers map[string]string
+
+	// Payload is arbitrary data that will be POSTed on the URL.
+	Payload string
+
+	// Schedule is a cron specification describing the recurrency if any.
+	Schedule string
+
+	// Timezone is the timezone of the Schedule, the one of the Application by default.
+	Timezone string
+
+	// Misfire is the policy of the runs missed while hooky was down.
+	Misfire string
+
+	// MisfireLimit is the maximum number of missed runs executed by the `fire_all` policy.
+	MisfireLimit int
+
+	// Overlap is the policy of the runs starting while an attempt is running.
+	Overlap string
+
+	// StartAt is a Unix timestamp in nanoseconds before which the Schedule has no run.
+	StartAt int64
+
+	// EndAt is a Unix timestamp in nanoseconds after which the Schedule has no run.
+	EndAt int64
+
+	// MaxRuns is the maximum number of runs of the Schedule.
+	MaxRuns int
+
+	// Jitter is the maximum delay in seconds added to the runs of the Schedule.
+	Jitter int
+
+	// Priority is the priority of the attempts of the Task.
+	Priority int
+
+	// At is a Unix timestamp in nanoseconds of the first attempt.
+	At int64
+
+	// Retry is the retry strategy parameters in case of errors.
+	Retry *Retry
+
+	// Timeout is the maximum duration of an attempt in seconds.
+	Timeout int
+
+	// Success are the HTTP status codes considered as a success.
+	Success string
+
+	// Active is false to create an inactive Task.
+	Active bool
 }
 
 // NewTask creates a new Task.
-func (b *Base) NewTask(account bson.ObjectId, applicationName string, name string, queueName string, URL string, auth HTTPAuth, method string, headers map[string]string, payload string, schedule string, retry *Retry, active bool) (task *Task, err error) {
+func (b *Base) NewTask(account bson.ObjectId, applicationName string, name string, options TaskOptions) (task *Task, err error) {
 	application, err := b.GetApplication(account, applicationName)
 	if application == nil {
 		return nil, ErrApplicationNotFound
@@ -133,46 +259,116 @@ func (b *Base) NewTask(account bson.ObjectId, applicationName string, name strin
 		name = taskID.Hex()
 	}
 	// Default queue is 'default'
-	if queueName == "" {
-		queueName = "default"
+	if options.Queue == "" {
+		options.Queue = "default"
 	}
-	queue, err := b.GetQueue(account, applicationName, queueName)
+	queue, err := b.GetQueue(account, applicationName, options.Queue)
 	if queue == nil {
 		return nil, ErrQueueNotFound
 	}
 	if err != nil {
 		return
 	}
+	// The URL must be executed by a registered Executor.
+	u, err := url.Parse(options.URL)
+	if err != nil {
+		return nil, err
+	}
+	if GetExecutor(u.Scheme) == nil {
+		return nil, ErrUnsupportedScheme
+	}
 	// Default method is POST.
-	if method == "" {
-		method = "POST"
+	if options.Method == "" {
+		options.Method = "POST"
 	}
 	// Payload is only valid for POST requests.
-	if method != "POST" {
-		payload = ""
+	if options.Method != "POST" {
+		options.Payload = ""
 	}
 	// Now as a Unix timestamp in nanoseconds
 	nowNano := time.Now().UnixNano()
-	// If schedule is defined we compute the next date of the first attempt,
+	// Default timezone is the one of the application.
+	if options.Timezone == "" {
+		options.Timezone = application.Timezone
+	}
+	if _, err = loadLocation(options.Timezone); err != nil {
+		return nil, err
+	}
+	// Default misfire policy is to skip the missed runs.
+	if options.Misfire == "" {
+		options.Misfire = "skip"
+	}
+	if _, ok := MisfirePolicies[options.Misfire]; !ok {
+		return nil, ErrInvalidMisfire
+	}
+	// Default overlap policy is to skip the overlapping runs.
+	if options.Overlap == "" {
+		options.Overlap = "forbid"
+	}
+	if _, ok := OverlapPolicies[options.Overlap]; !ok {
+		return nil, ErrInvalidOverlap
+	}
+	if options.StartAt > 0 && options.EndAt > 0 && options.EndAt < options.StartAt {
+		return nil, ErrInvalidWindow
+	}
+	// Default jitter is the one of the queue.
+	if options.Jitter == 0 {
+		options.Jitter = queue.Jitter
+	}
+	if options.Jitter < 0 {
+		return nil, ErrInvalidJitter
+	}
+	// If at is defined it is the date of the first attempt, otherwise if
+	// schedule is defined we compute the next date of the first attempt,
 	// otherwise it is right now.
-	var at int64
-	if schedule != "" {
-		at, err = nextRun(schedule)
-		if err != nil {
+	var scheduledAt int64
+	if options.Schedule != "" {
+		var next int64
+		if next, err = nextRun(options.Schedule, options.Timezone, options.StartAt, options.EndAt, time.Now()); err != nil {
 			return
 		}
-	} else {
-		at = nowNano
+		if next == 0 {
+			return nil, ErrNoRunInWindow
+		}
+		if options.At == 0 {
+			options.At = next + jitterOffset(JitterSeed(account, applicationName, name), options.Jitter)
+			scheduledAt = next
+		}
+	}
+	if options.At == 0 {
+		options.At = nowNano
+	} else if options.At < nowNano-1000000000 {
+		return nil, ErrAtInPast
 	}
 	// Define default parameters for our retry strategy.
-	if retry == nil {
+	if options.Retry == nil {
 		if queue.Retry != nil {
-			retry = queue.Retry
+			options.Retry = queue.Retry
 		} else {
-			retry = &Retry{}
+			options.Retry = &Retry{}
 		}
 	}
-	retry.SetDefault()
+	options.Retry.SetDefault()
+	if err = options.Retry.Validate(); err != nil {
+		return nil, err
+	}
+	// Default timeout is the one of the queue.
+	if options.Timeout == 0 {
+		options.Timeout = queue.Timeout
+	}
+	if options.Timeout < 0 {
+		return nil, ErrInvalidTimeout
+	}
+	// Default success status codes are the ones of the queue.
+	if options.Success == "" {
+		options.Success = queue.Success
+	}
+	if options.Success == "" {
+		options.Success = DefaultSuccess
+	}
+	if _, err = ParseStatusCodes(options.Success); err != nil {
+		return nil, err
+	}
 
 	currentAttempt := bson.NewObjectId()
 
@@ -184,18 +380,30 @@ func (b *Base) NewTask(account bson.ObjectId, applicationName string, name strin
 		Queue:          queue.Name,
 		QueueID:        queue.ID,
 		Name:           name,
-		URL:            URL,
-		HTTPAuth:       auth,
-		Method:         method,
-		Headers:        headers,
-		Payload:        payload,
-		At:             at,
+		URL:            options.URL,
+		HTTPAuth:       options.HTTPAuth,
+		Method:         options.Method,
+		Headers:        options.Headers,
+		Payload:        options.Payload,
+		At:             options.At,
 		Status:         "pending",
-		Active:         at > 0 && active,
-		Schedule:       schedule,
+		Active:         options.At > 0 && options.Active,
+		Schedule:       options.Schedule,
+		Timezone:       options.Timezone,
+		Misfire:        options.Misfire,
+		MisfireLimit:   options.MisfireLimit,
+		Overlap:        options.Overlap,
+		StartAt:        options.StartAt,
+		EndAt:          options.EndAt,
+		MaxRuns:        options.MaxRuns,
+		Jitter:         options.Jitter,
+		Priority:       options.Priority,
+		ScheduledAt:    scheduledAt,
 		CurrentAttempt: currentAttempt,
 		AttemptUpdated: nowNano,
-		Retry:          retry,
+		Retry:          options.Retry,
+		Timeout:        options.Timeout,
+		Success:        options.Success,
 	}
 	err = b.db.C("tasks").Insert(task)
 	_, err = b.ShouldRefreshSession(err)
@@ -206,31 +414,65 @@ func (b *Base) NewTask(account bson.ObjectId, applicationName string, name strin
 			err = nil
 		}
 	} else if mgo.IsDup(err) {
+		query := bson.M{
+			"account":     task.Account,
+			"application": task.Application,
+			"name":        task.Name,
+		}
+		current := &Task{}
+		err = b.db.C("tasks").Find(query).One(current)
+		if _, err = b.ShouldRefreshSession(err); err != nil {
+			return nil, err
+		}
+		// The runs are kept unless the schedule or its window changed.
+		if !current.Deleted && current.Schedule == task.Schedule && current.Timezone == task.Timezone && current.StartAt == task.StartAt && current.EndAt == task.EndAt {
+			task.Runs = current.Runs
+			task.StartedRuns = current.StartedRuns
+		}
+		if task.Schedule != "" && task.MaxRuns > 0 && task.runsCount() >= task.MaxRuns {
+			// The schedule already reached its maximum number of runs.
+			task.Status = "completed"
+			task.At = 0
+			task.ScheduledAt = 0
+		}
 		change := mgo.Change{
 			Update: bson.M{
 				"$set": bson.M{
 					"url":             task.URL,
+					"status":          task.Status,
 					"method":          task.Method,
 					"headers":         task.Headers,
 					"payload":         task.Payload,
 					"at":              task.At,
-					"active":          task.At > 0 && active,
+					"active":          task.At > 0 && options.Active,
 					"schedule":        task.Schedule,
+					"timezone":        task.Timezone,
+					"misfire":         task.Misfire,
+					"misfire_limit":   task.MisfireLimit,
+					"overlap":         task.Overlap,
+					"start_at":        task.StartAt,
+					"end_at":          task.EndAt,
+					"max_runs":        task.MaxRuns,
+					"jitter":          task.Jitter,
+					"priority":        task.Priority,
+					"runs":            task.Runs,
+					"started_runs":    task.StartedRuns,
+					"misfires":        0,
+					"scheduled_at":    task.ScheduledAt,
 					"retry":           task.Retry,
+					"timeout":         task.Timeout,
+					"success":         task.Success,
 					"auth":            task.HTTPAuth,
 					"current_attempt": currentAttempt,
 					"attempt_queued":  false,
 					"attempt_updated": nowNano,
 					"deleted":         false,
+					"paused":          false,
+					"paused_at":       int64(0),
 				},
 			},
 			ReturnNew: true,
 		}
-		query := bson.M{
-			"account":     task.Account,
-			"application": task.Application,
-			"name":        task.Name,
-		}
 		_, err = b.db.C("tasks").Find(query).Apply(change, task)
 		_, err = b.ShouldRefreshSession(err)
 		if err == nil {
@@ -383,26 +625,91 @@ func (b *Base) NextAttemptForTask(attempt *Attempt) (nextAttempt *Attempt, err e
 		return nil, err
 	}
 
-	var at int64
-	if task.Active && task.Schedule != "" {
-		at, err = nextRun(task.Schedule)
+	now := time.Now().UTC()
+
+	isLatestAttempt := task.CurrentAttempt == attempt.ID
+
+	// A canceled Task has no next attempt.
+	if task.Status == "canceled" {
+		return nil, b.finishCanceledAttempt(task, attempt, now)
 	}
 
-	now := time.Now().UTC()
+	// With the `allow` overlap policy the next run was scheduled when this attempt started.
+	if !isLatestAttempt && task.Overlap == "allow" {
+		return b.finishParallelAttempt(task, attempt, now)
+	}
+
+	// The run of this attempt is counted unless it is retried.
+	task.Runs++
+
+	var at, scheduledAt int64
+	var skipped []int64
+	misfires := 0
+	noRunLeft := false
+	if task.Active && task.Schedule != "" {
+		var run scheduledRun
+		run, err = task.nextScheduledRun(attempt, now)
+		at, scheduledAt, misfires, skipped = run.at, run.scheduledAt, run.misfires, run.skipped
+		// The validity window of the schedule is closed or MaxRuns is reached.
+		noRunLeft = err == nil && run.at == 0
+	}
 
 	errors := 0
 	retryAttempts := 1
-	if status == "error" {
-		errors = 1
-		at, err = task.Retry.NextAttempt(now.UnixNano())
-		if err == nil {
-			status = "retrying"
+	if status == "aborted" && !attempt.abortRequested() {
+		// An attempt aborted by a shutdown is retried right away without
+		// using the retry budget.
+		at = now.UnixNano()
+		status = "retrying"
+		retryAttempts = 0
+		scheduledAt = attempt.ScheduledAt
+		misfires = task.Misfires
+		skipped = nil
+	} else if status == "error" || status == "aborted" {
+		// The abort requested through the API is permanent.
+		if status == "error" {
+			errors = 1
+		}
+		if attempt.Permanent || task.Retry.IsPermanent(int(attempt.StatusCode)) || attempt.abortRequested() {
+			// Permanent failure: we wait for the next scheduled run if any.
+			ModelsTaskDebug("Attempt [%s] failed permanently with status code %d", attempt.ID.Hex(), attempt.StatusCode)
+			retryAttempts = -task.Retry.Attempts
+		} else {
+			at, err = task.Retry.NextAttempt(now.UnixNano())
+			if err == nil {
+				status = "retrying"
+				scheduledAt = attempt.ScheduledAt
+				misfires = task.Misfires
+				skipped = nil
+				if attempt.RetryAfter > 0 {
+					at = task.Retry.RetryAfter(now.UnixNano(), attempt.RetryAfter)
+				}
+			}
 		}
 	} else if status == "success" {
 		retryAttempts = -task.Retry.Attempts
 	}
 
-	isLatestAttempt := task.CurrentAttempt == attempt.ID
+	// An aborted attempt that is not retried leaves the task in error.
+	if status == "aborted" {
+		status = "error"
+	}
+
+	runs := 1
+	taskStatus := status
+	if status == "retrying" {
+		runs = 0
+	} else if noRunLeft && status != "error" {
+		// A last run that failed keeps the task in error, the parallel
+		// runs complete the task when they finish.
+		running, err := b.hasOtherAttempts(task, attempt)
+		if err != nil {
+			return nil, err
+		}
+		if !running {
+			taskStatus = "completed"
+		}
+	}
 
 	nextAttemptID := bson.NewObjectId()
 	if !isLatestAttempt {
@@ -413,12 +720,14 @@ func (b *Base) NextAttemptForTask(attempt *Attempt) (nextAttempt *Attempt, err e
 	change := mgo.Change{
 		Update: bson.M{
 			"$set": bson.M{
-				"status":          status,
+				"status":          taskStatus,
 				"updated":         now.Unix(),
 				"executed":        now.Unix(),
 				"last_" + status:  now.Unix(),
 				"at":              at,
-				"active":          at > 0,
+				"scheduled_at":    scheduledAt,
+				"misfires":        misfires,
+				"active":          at > 0 && task.Active,
 				"current_attempt": nextAttemptID,
 				"attempt_queued":  false,
 				"attempt_updated": time.Now().UnixNano(),
@@ -427,6 +736,8 @@ func (b *Base) NextAttemptForTask(attempt *Attempt) (nextAttempt *Attempt, err e
 				"executions":     1,
 				"errors":         errors,
 				"retry.attempts": retryAttempts,
+				"skipped":        len(skipped),
+				"runs":           runs,
 			},
 		},
 		ReturnNew: true,
@@ -437,7 +748,10 @@ func (b *Base) NextAttemptForTask(attempt *Attempt) (nextAttempt *Attempt, err e
 	if err != nil {
 		return nil, err
 	}
-	if err == nil && isLatestAttempt {
+	if err = b.recordSkippedRuns(newTask, skipped); err != nil {
+		log.Printf("NextAttemptForTask error while recording skipped runs: %s\n", err)
+	}
+	if isLatestAttempt {
 		nextAttempt, err = b.NewAttempt(newTask, true, false)
 	}
 	if err = b.AckAttempt(attempt.ID); err != nil {
@@ -463,8 +777,9 @@ func (b *Base) ForceAttemptForTask(account bson.ObjectId, application string, na
 				"attempt_updated": time.Now().UnixNano(),
 			},
 		},
+		ReturnNew: true,
 	}
-	var task *Task
+	task := &Task{}
 	_, err = b.db.C("tasks").Find(query).Apply(change, task)
 	_, err = b.ShouldRefreshSession(err)
 	if err != nil {
@@ -535,7 +850,7 @@ func (b *Base) FixIntegrity() error {
 
 	// Fixing Attempts
 	query = bson.M{
-		"status":   bson.M{"$in": []string{"success", "error"}},
+		"status":   bson.M{"$in": []string{"success", "error", "aborted"}},
 		"finished": bson.M{"$lte": time.Now().Unix() - 180},
 		"acked":    false,
 	}
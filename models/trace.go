@@ -0,0 +1,92 @@
+package models
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timing is the timing breakdown of an HTTP request in milliseconds.
+type Timing struct {
+	// DNS is the duration of the DNS lookup.
+	DNS float64 `bson:"dns" json:"dns"`
+	// Connect is the duration to establish the TCP connection.
+	Connect float64 `bson:"connect" json:"connect"`
+	// TLS is the duration of the TLS handshake.
+	TLS float64 `bson:"tls" json:"tls"`
+	// FirstByte is the duration until the first byte of the response.
+	FirstByte float64 `bson:"first_byte" json:"firstByte"`
+	// Total is the total duration of the request including reading the response.
+	Total float64 `bson:"total" json:"total"`
+}
+
+// tracer collects the Timing of an HTTP request.
+type tracer struct {
+	sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	timing       Timing
+}
+
+func newTracer() *tracer {
+	return &tracer{
+		start: time.Now(),
+	}
+}
+
+func sinceMilliseconds(t time.Time) float64 {
+	return float64(time.Since(t)) / float64(time.Millisecond)
+}
+
+// ClientTrace returns the hooks to use with httptrace.
+func (t *tracer) ClientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.Lock()
+			t.dnsStart = time.Now()
+			t.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.Lock()
+			t.timing.DNS = sinceMilliseconds(t.dnsStart)
+			t.Unlock()
+		},
+		ConnectStart: func(network, addr string) {
+			t.Lock()
+			t.connectStart = time.Now()
+			t.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			t.Lock()
+			t.timing.Connect = sinceMilliseconds(t.connectStart)
+			t.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			t.Lock()
+			t.tlsStart = time.Now()
+			t.Unlock()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.Lock()
+			t.timing.TLS = sinceMilliseconds(t.tlsStart)
+			t.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			t.Lock()
+			t.timing.FirstByte = sinceMilliseconds(t.start)
+			t.Unlock()
+		},
+	}
+}
+
+// Done stops the tracer and returns the Timing.
+func (t *tracer) Done() *Timing {
+	t.Lock()
+	defer t.Unlock()
+	t.timing.Total = sinceMilliseconds(t.start)
+	timing := t.timing
+	return &timing
+}
@@ -28,6 +28,10 @@ type Account struct {
 
 	// Key is the secret key to authenticate the Account ID.
 	Key string `json:"key"`
+
+	// MaxInFlightPerHost is the maximum number of attempts executed in
+	// parallel for a target host across all the queues, 0 means no limit.
+	MaxInFlightPerHost *int `json:"maxInFlightPerHost,omitempty"`
 }
 
 func PathAccountID(r *rest.Request) (bson.ObjectId, error) {
@@ -45,10 +49,11 @@ func accountParams(r *rest.Request) (bson.ObjectId, error) {
 // NewAccountFromModel returns an API Account given a model Account.
 func NewAccountFromModel(account *models.Account) *Account {
 	return &Account{
-		ID:      account.ID.Hex(),
-		Name:    account.Name,
-		Created: account.ID.Time().UTC().Format(time.RFC3339),
-		Key:     account.Key,
+		ID:                 account.ID.Hex(),
+		Name:               account.Name,
+		Created:            account.ID.Time().UTC().Format(time.RFC3339),
+		Key:                account.Key,
+		MaxInFlightPerHost: &account.MaxInFlightPerHost,
 	}
 }
 
@@ -67,12 +72,12 @@ func PostAccount(w rest.ResponseWriter, r *rest.Request) {
 		rest.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	_, err = b.NewApplication(account.ID, "default")
+	_, err = b.NewApplication(account.ID, "default", "")
 	if err != nil {
 		rest.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	_, err = b.NewQueue(account.ID, "default", "default", nil, 0)
+	_, err = b.NewQueue(account.ID, "default", "default", models.QueueOptions{})
 	if err != nil {
 		rest.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -95,7 +100,7 @@ func PatchAccount(w rest.ResponseWriter, r *rest.Request) {
 		}
 	}
 	b := GetBase(r)
-	account, err := b.UpdateAccount(accountID, rc.Name)
+	account, err := b.UpdateAccount(accountID, rc.Name, rc.MaxInFlightPerHost)
 	if err != nil {
 		rest.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -22,6 +22,27 @@ type Application struct {
 
 	// Name is the application's name.
 	Name string `json:"name"`
+
+	// Timezone is the IANA name of the default timezone of the Tasks.
+	Timezone string `json:"timezone,omitempty"`
+
+	// Secret is the secret key used to sign the requests.
+	Secret string `json:"secret"`
+
+	// PreviousSecretExpires is the date until when the previous secret is used.
+	PreviousSecretExpires string `json:"previousSecretExpires,omitempty"`
+
+	// Paused is true when the dispatch of the attempts is paused.
+	Paused bool `json:"paused"`
+
+	// PausedAt is the date when the Application was paused.
+	PausedAt string `json:"pausedAt,omitempty"`
+}
+
+// ApplicationSecret is used to rotate the secret of an Application.
+type ApplicationSecret struct {
+	// GracePeriod is the duration in seconds during which the previous secret is still used.
+	GracePeriod int `json:"gracePeriod"`
 }
 
 func applicationParams(r *rest.Request) (bson.ObjectId, string, error) {
@@ -41,10 +62,15 @@ func applicationParams(r *rest.Request) (bson.ObjectId, string, error) {
 // from a Application model.
 func NewApplicationFromModel(application *models.Application) *Application {
 	return &Application{
-		ID:      application.ID.Hex(),
-		Created: application.ID.Time().UTC().Format(time.RFC3339),
-		Account: application.Account.Hex(),
-		Name:    application.Name,
+		ID:                    application.ID.Hex(),
+		Created:               application.ID.Time().UTC().Format(time.RFC3339),
+		Account:               application.Account.Hex(),
+		Name:                  application.Name,
+		Timezone:              application.Timezone,
+		Secret:                application.Secret,
+		PreviousSecretExpires: UnixToRFC3339(application.PreviousSecretExpires),
+		Paused:                application.Paused,
+		PausedAt:              UnixToRFC3339(application.PausedAt),
 	}
 }
 
@@ -64,12 +90,12 @@ func PutApplication(w rest.ResponseWriter, r *rest.Request) {
 		}
 	}
 	b := GetBase(r)
-	application, err := b.NewApplication(accountID, applicationName)
+	application, err := b.NewApplication(accountID, applicationName, rc.Timezone)
 	if err != nil {
 		rest.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	_, err = b.NewQueue(accountID, applicationName, "default", nil, 0)
+	_, err = b.NewQueue(accountID, applicationName, "default", models.QueueOptions{})
 	if err != nil {
 		rest.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -98,6 +124,34 @@ func GetApplication(w rest.ResponseWriter, r *rest.Request) {
 	w.WriteJson(NewApplicationFromModel(application))
 }
 
+// PostApplicationSecret ...
+func PostApplicationSecret(w rest.ResponseWriter, r *rest.Request) {
+	accountID, applicationName, err := applicationParams(r)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rc := &ApplicationSecret{}
+	if err := r.DecodeJsonPayload(rc); err != nil {
+		if err != rest.ErrJsonPayloadEmpty {
+			rest.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	b := GetBase(r)
+	application, err := b.RotateApplicationSecret(accountID, applicationName, rc.GracePeriod)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if application == nil {
+		rest.NotFound(w, r)
+		return
+	}
+	w.WriteJson(NewApplicationFromModel(application))
+}
+
 // DeleteApplication ...
 func DeleteApplication(w rest.ResponseWriter, r *rest.Request) {
 	accountID, applicationName, err := applicationParams(r)
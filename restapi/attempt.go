@@ -56,10 +56,19 @@ type Attempt struct {
 	// At is a date representing the time this attempt will be executed.
 	At string `json:"at,omitempty"`
 
+	// ScheduledAt is the date of the run of the schedule this attempt belongs to.
+	ScheduledAt string `json:"scheduledAt,omitempty"`
+
+	// Priority is the priority of the attempt, higher priorities are executed first.
+	Priority int `json:"priority"`
+
+	// Started is the date when the attempt started.
+	Started string `json:"started,omitempty"`
+
 	// Finished is a Unix timestamp representing the time the attempt finished.
 	Finished string `json:"finished,omitempty"`
 
-	// Status is either `pending`, `retrying`, `canceled`, `success` or `error`
+	// Status is either `pending`, `retrying`, `canceled`, `success`, `error` or `aborted`
 	Status string `json:"status"`
 
 	// StatusCode is the HTTP status code.
@@ -67,29 +76,44 @@ type Attempt struct {
 
 	// StatusMessage is a human readable message related to the StatusCode.
 	StatusMessage string `json:"statusMessage,omitempty"`
+
+	// ResponseBody is the beginning of the body of the HTTP response.
+	ResponseBody string `json:"responseBody,omitempty"`
+
+	// ResponseHeaders are the headers of the HTTP response.
+	ResponseHeaders map[string]string `json:"responseHeaders,omitempty"`
+
+	// Timing is the timing breakdown of the HTTP request in milliseconds.
+	Timing *models.Timing `json:"timing,omitempty"`
 }
 
 // NewAttemptFromModel returns a Task object for use with the Rest API
 // from a Task model.
 func NewAttemptFromModel(attempt *models.Attempt) *Attempt {
 	return &Attempt{
-		ID:            attempt.ID.Hex(),
-		Created:       attempt.ID.Time().UTC().Format(time.RFC3339),
-		Application:   attempt.Application,
-		Account:       attempt.Account.Hex(),
-		Queue:         attempt.Queue,
-		Task:          attempt.Task,
-		TaskID:        attempt.TaskID.Hex(),
-		URL:           attempt.URL,
-		Method:        attempt.Method,
-		HTTPAuth:      attempt.HTTPAuth,
-		Headers:       attempt.Headers,
-		Payload:       attempt.Payload,
-		At:            UnixToRFC3339(int64(attempt.At / 1000000000)),
-		Finished:      UnixToRFC3339(attempt.Finished),
-		Status:        attempt.Status,
-		StatusCode:    attempt.StatusCode,
-		StatusMessage: attempt.StatusMessage,
+		ID:              attempt.ID.Hex(),
+		Created:         attempt.ID.Time().UTC().Format(time.RFC3339),
+		Application:     attempt.Application,
+		Account:         attempt.Account.Hex(),
+		Queue:           attempt.Queue,
+		Task:            attempt.Task,
+		TaskID:          attempt.TaskID.Hex(),
+		URL:             attempt.URL,
+		Method:          attempt.Method,
+		HTTPAuth:        attempt.HTTPAuth,
+		Headers:         attempt.Headers,
+		Payload:         attempt.Payload,
+		At:              UnixToRFC3339(int64(attempt.At / 1000000000)),
+		ScheduledAt:     UnixToRFC3339(attempt.ScheduledAt / 1000000000),
+		Priority:        attempt.Priority,
+		Started:         UnixToRFC3339(attempt.Started / 1000000000),
+		Finished:        UnixToRFC3339(attempt.Finished),
+		Status:          attempt.Status,
+		StatusCode:      attempt.StatusCode,
+		StatusMessage:   attempt.StatusMessage,
+		ResponseBody:    attempt.ResponseBody,
+		ResponseHeaders: attempt.ResponseHeaders,
+		Timing:          attempt.Timing,
 	}
 }
 
@@ -171,3 +195,32 @@ func GetAttempt(w rest.ResponseWriter, r *rest.Request) {
 	}
 	w.WriteJson(NewAttemptFromModel(attempt))
 }
+
+// DeleteAttempt aborts a running attempt.
+func DeleteAttempt(w rest.ResponseWriter, r *rest.Request) {
+	accountID, applicationName, taskName, err := taskParams(r)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	attemptID := r.PathParam("attempt")
+	if !bson.IsObjectIdHex(attemptID) {
+		rest.Error(w, ErrInvalidAttemptID.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	b := GetBase(r)
+	attempt, err := b.AbortAttempt(accountID, applicationName, taskName, bson.ObjectIdHex(attemptID))
+	if err == models.ErrAttemptNotRunning {
+		rest.Error(w, err.Error(), http.StatusConflict)
+		return
+	} else if err != nil {
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if attempt == nil {
+		rest.NotFound(w, r)
+		return
+	}
+	w.WriteJson(NewAttemptFromModel(attempt))
+}
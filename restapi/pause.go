@@ -0,0 +1,157 @@
+package restapi
+
+import (
+	"net/http"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/sebest/hooky/models"
+)
+
+// Resume is used to resume a paused Queue or Application.
+type Resume struct {
+	// Missed is the policy for the overdue attempts: `run_all` or `skip`.
+	Missed string `json:"missed"`
+}
+
+// PostQueuePause ...
+func PostQueuePause(w rest.ResponseWriter, r *rest.Request) {
+	accountID, applicationName, queueName, err := queueParams(r)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	b := GetBase(r)
+	queue, err := b.PauseQueue(accountID, applicationName, queueName)
+	if err == models.ErrQueueNotFound {
+		rest.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteJson(NewQueueFromModel(queue))
+}
+
+// PostQueueResume ...
+func PostQueueResume(w rest.ResponseWriter, r *rest.Request) {
+	accountID, applicationName, queueName, err := queueParams(r)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rc := &Resume{}
+	if err := r.DecodeJsonPayload(rc); err != nil {
+		if err != rest.ErrJsonPayloadEmpty {
+			rest.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	b := GetBase(r)
+	queue, err := b.ResumeQueue(accountID, applicationName, queueName, rc.Missed)
+	if err == models.ErrQueueNotFound {
+		rest.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteJson(NewQueueFromModel(queue))
+}
+
+// PostApplicationPause ...
+func PostApplicationPause(w rest.ResponseWriter, r *rest.Request) {
+	accountID, applicationName, err := applicationParams(r)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	b := GetBase(r)
+	application, err := b.PauseApplication(accountID, applicationName)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if application == nil {
+		rest.NotFound(w, r)
+		return
+	}
+	w.WriteJson(NewApplicationFromModel(application))
+}
+
+// PostApplicationResume ...
+func PostApplicationResume(w rest.ResponseWriter, r *rest.Request) {
+	accountID, applicationName, err := applicationParams(r)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rc := &Resume{}
+	if err := r.DecodeJsonPayload(rc); err != nil {
+		if err != rest.ErrJsonPayloadEmpty {
+			rest.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	b := GetBase(r)
+	application, err := b.ResumeApplication(accountID, applicationName, rc.Missed)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if application == nil {
+		rest.NotFound(w, r)
+		return
+	}
+	w.WriteJson(NewApplicationFromModel(application))
+}
+
+// PostTaskPause ...
+func PostTaskPause(w rest.ResponseWriter, r *rest.Request) {
+	accountID, applicationName, taskName, err := taskParams(r)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	b := GetBase(r)
+	task, err := b.PauseTask(accountID, applicationName, taskName)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if task == nil {
+		rest.NotFound(w, r)
+		return
+	}
+	w.WriteJson(NewTaskFromModel(task))
+}
+
+// PostTaskResume ...
+func PostTaskResume(w rest.ResponseWriter, r *rest.Request) {
+	accountID, applicationName, taskName, err := taskParams(r)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	b := GetBase(r)
+	task, err := b.ResumeTask(accountID, applicationName, taskName)
+	if err == models.ErrTaskNotPaused {
+		rest.Error(w, err.Error(), http.StatusConflict)
+		return
+	} else if err != nil {
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if task == nil {
+		rest.NotFound(w, r)
+		return
+	}
+	w.WriteJson(NewTaskFromModel(task))
+}
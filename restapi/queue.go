@@ -34,6 +34,36 @@ type Queue struct {
 
 	// InFlight is the current number of attempts executed in parallel.
 	InFlight int `json:"inFlight"`
+
+	// Timeout is the default maximum duration of an attempt in seconds.
+	Timeout int `json:"timeout"`
+
+	// Success is the default list of HTTP status codes considered as a success.
+	Success string `json:"success"`
+
+	// MaxResponseBody is the maximum size in bytes of a response body stored with an attempt.
+	MaxResponseBody int `json:"maxResponseBody"`
+
+	// MaxResponseHeaders is the maximum number of response headers stored with an attempt.
+	MaxResponseHeaders int `json:"maxResponseHeaders"`
+
+	// Jitter is the default maximum delay in seconds added to the runs of the schedules.
+	Jitter int `json:"jitter"`
+
+	// PriorityAging is the duration in seconds after which the priority of a pending attempt is raised by one.
+	PriorityAging int `json:"priorityAging"`
+
+	// RateLimit limits the number of attempts started per second if any.
+	RateLimit *models.RateLimit `json:"rateLimit,omitempty"`
+
+	// Tokens is the current number of tokens in the bucket of the RateLimit.
+	Tokens *float64 `json:"tokens,omitempty"`
+
+	// Paused is true when the dispatch of the attempts is paused.
+	Paused bool `json:"paused"`
+
+	// PausedAt is the date when the Queue was paused.
+	PausedAt string `json:"pausedAt,omitempty"`
 }
 
 func queueParams(r *rest.Request) (bson.ObjectId, string, string, error) {
@@ -50,15 +80,30 @@ func queueParams(r *rest.Request) (bson.ObjectId, string, string, error) {
 // NewQueueFromModel returns a Queue object for use with the Rest API
 // from a Queue model.
 func NewQueueFromModel(queue *models.Queue) *Queue {
+	var tokens *float64
+	if queue.RateLimit != nil {
+		available := queue.AvailableTokens()
+		tokens = &available
+	}
 	return &Queue{
-		ID:          queue.ID.Hex(),
-		Created:     queue.ID.Time().UTC().Format(time.RFC3339),
-		Account:     queue.Account.Hex(),
-		Application: queue.Application,
-		Name:        queue.Name,
-		Retry:       queue.Retry,
-		MaxInFlight: queue.MaxInFlight,
-		InFlight:    len(queue.AttemptsInFlight),
+		ID:                 queue.ID.Hex(),
+		Created:            queue.ID.Time().UTC().Format(time.RFC3339),
+		Account:            queue.Account.Hex(),
+		Application:        queue.Application,
+		Name:               queue.Name,
+		Retry:              queue.Retry,
+		MaxInFlight:        queue.MaxInFlight,
+		InFlight:           len(queue.AttemptsInFlight),
+		Timeout:            queue.Timeout,
+		Success:            queue.Success,
+		MaxResponseBody:    queue.MaxResponseBody,
+		MaxResponseHeaders: queue.MaxResponseHeaders,
+		Jitter:             queue.Jitter,
+		PriorityAging:      queue.PriorityAging,
+		RateLimit:          queue.RateLimit,
+		Tokens:             tokens,
+		Paused:             queue.Paused,
+		PausedAt:           UnixToRFC3339(queue.PausedAt),
 	}
 }
 
@@ -76,7 +121,18 @@ func PutQueue(w rest.ResponseWriter, r *rest.Request) {
 		return
 	}
 	b := GetBase(r)
-	queue, err := b.NewQueue(accountID, applicationName, queueName, rc.Retry, rc.MaxInFlight)
+	options := models.QueueOptions{
+		Retry:              rc.Retry,
+		MaxInFlight:        rc.MaxInFlight,
+		Timeout:            rc.Timeout,
+		Success:            rc.Success,
+		MaxResponseBody:    rc.MaxResponseBody,
+		MaxResponseHeaders: rc.MaxResponseHeaders,
+		Jitter:             rc.Jitter,
+		PriorityAging:      rc.PriorityAging,
+		RateLimit:          rc.RateLimit,
+	}
+	queue, err := b.NewQueue(accountID, applicationName, queueName, options)
 	if err != nil {
 		rest.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -84,7 +84,7 @@ func Authenticate(w rest.ResponseWriter, r *rest.Request) {
 }
 
 // New creates a new instance of the Rest API.
-func New(s *store.Store, adminPassword string, logStyle string) (*rest.Api, error) {
+func New(s *store.Store, adminPassword string, logStyle string, role string) (*rest.Api, error) {
 	api := rest.NewApi()
 	if logStyle == "json" {
 		api.Use(&rest.AccessLogJsonMiddleware{})
@@ -138,10 +138,16 @@ func New(s *store.Store, adminPassword string, logStyle string) (*rest.Api, erro
 		rest.Get("/accounts/:account/applications/:application", GetApplication),
 		rest.Put("/accounts/:account/applications/:application", PutApplication),
 		rest.Delete("/accounts/:account/applications/:application", DeleteApplication),
+		rest.Post("/accounts/:account/applications/:application/secret", PostApplicationSecret),
+		rest.Post("/accounts/:account/applications/:application/pause", PostApplicationPause),
+		rest.Post("/accounts/:account/applications/:application/resume", PostApplicationResume),
+		rest.Post("/accounts/:account/applications/:application/schedules/preview", PostSchedulePreview),
 		rest.Get("/accounts/:account/applications/:application/queues", GetQueues),
 		rest.Put("/accounts/:account/applications/:application/queues/:queue", PutQueue),
 		rest.Delete("/accounts/:account/applications/:application/queues/:queue", DeleteQueue),
 		rest.Get("/accounts/:account/applications/:application/queues/:queue", GetQueue),
+		rest.Post("/accounts/:account/applications/:application/queues/:queue/pause", PostQueuePause),
+		rest.Post("/accounts/:account/applications/:application/queues/:queue/resume", PostQueueResume),
 		rest.Delete("/accounts/:account/applications/:application/queues", DeleteQueues),
 		rest.Post("/accounts/:account/applications/:application/tasks", PutTask),
 		rest.Get("/accounts/:account/applications/:application/tasks", GetTasks),
@@ -149,10 +155,14 @@ func New(s *store.Store, adminPassword string, logStyle string) (*rest.Api, erro
 		rest.Put("/accounts/:account/applications/:application/tasks/:task", PutTask),
 		rest.Get("/accounts/:account/applications/:application/tasks/:task", GetTask),
 		rest.Delete("/accounts/:account/applications/:application/tasks/:task", DeleteTask),
+		rest.Post("/accounts/:account/applications/:application/tasks/:task/pause", PostTaskPause),
+		rest.Post("/accounts/:account/applications/:application/tasks/:task/resume", PostTaskResume),
+		rest.Post("/accounts/:account/applications/:application/tasks/:task/cancel", PostTaskCancel),
 		rest.Post("/accounts/:account/applications/:application/tasks/:task/attempts", PostAttempt),
 		rest.Get("/accounts/:account/applications/:application/tasks/:task/attempts", GetAttempts),
 		rest.Get("/accounts/:account/applications/:application/tasks/:task/attempts/:attempt", GetAttempt),
-		rest.Get("/status", GetStatus),
+		rest.Delete("/accounts/:account/applications/:application/tasks/:task/attempts/:attempt", DeleteAttempt),
+		rest.Get("/status", GetStatus(role)),
 	)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,131 @@
+package restapi
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/sebest/hooky/models"
+)
+
+const (
+	// DefaultPreviewCount is the default number of runs returned by a SchedulePreview.
+	DefaultPreviewCount = 10
+
+	// MaxPreviewCount is the maximum number of runs returned by a SchedulePreview.
+	MaxPreviewCount = 100
+
+	// TaskNextRunsCount is the number of runs returned with a Task.
+	TaskNextRunsCount = 5
+)
+
+// SchedulePreview is used to validate a schedule and preview its next runs.
+type SchedulePreview struct {
+	// Schedule is a cron specification, it is normalized in the response.
+	Schedule string `json:"schedule"`
+
+	// Timezone is the IANA name of the timezone used to evaluate the Schedule.
+	Timezone string `json:"timezone,omitempty"`
+
+	// Jitter is the maximum delay in seconds added to the runs of the Schedule.
+	Jitter int `json:"jitter,omitempty"`
+
+	// Task is the name of the Task whose jitter offset is applied to the runs.
+	Task string `json:"task,omitempty"`
+
+	// StartAt is the date before which the Schedule has no run.
+	StartAt string `json:"startAt,omitempty"`
+
+	// EndAt is the date after which the Schedule has no run.
+	EndAt string `json:"endAt,omitempty"`
+
+	// Count is the number of runs to return.
+	Count int `json:"count,omitempty"`
+
+	// Valid is true if the Schedule and the Timezone are valid.
+	Valid bool `json:"valid"`
+
+	// Error is the reason why the Schedule or the Timezone is invalid.
+	Error string `json:"error,omitempty"`
+
+	// NextRuns are the dates of the next runs in the Timezone.
+	NextRuns []string `json:"nextRuns"`
+}
+
+// formatRuns converts a list of Unix timestamps in nanoseconds to dates in a timezone.
+func formatRuns(runs []int64, timezone string) []string {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	dates := make([]string, len(runs))
+	for idx, run := range runs {
+		dates[idx] = time.Unix(0, run).In(loc).Format(time.RFC3339)
+	}
+	return dates
+}
+
+// PostSchedulePreview ...
+func PostSchedulePreview(w rest.ResponseWriter, r *rest.Request) {
+	accountID, applicationName, err := applicationParams(r)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rs := &SchedulePreview{}
+	if err := r.DecodeJsonPayload(rs); err != nil {
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var startAt, endAt int64
+	if rs.StartAt != "" {
+		t, err := time.Parse(time.RFC3339, rs.StartAt)
+		if err != nil {
+			rest.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		startAt = t.UnixNano()
+	}
+	if rs.EndAt != "" {
+		t, err := time.Parse(time.RFC3339, rs.EndAt)
+		if err != nil {
+			rest.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		endAt = t.UnixNano()
+	}
+	if rs.Count <= 0 {
+		rs.Count = DefaultPreviewCount
+	} else if rs.Count > MaxPreviewCount {
+		rs.Count = MaxPreviewCount
+	}
+	b := GetBase(r)
+	// Default timezone is the one of the application.
+	if rs.Timezone == "" {
+		application, err := b.GetApplication(accountID, applicationName)
+		if err != nil {
+			rest.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if application == nil {
+			rest.NotFound(w, r)
+			return
+		}
+		rs.Timezone = application.Timezone
+	}
+	var seed string
+	if rs.Task != "" {
+		seed = models.JitterSeed(accountID, applicationName, rs.Task)
+	}
+	schedule, runs, err := models.PreviewSchedule(rs.Schedule, rs.Timezone, startAt, endAt, rs.Jitter, seed, time.Now(), rs.Count)
+	if err != nil {
+		rs.Error = err.Error()
+		rs.NextRuns = []string{}
+	} else {
+		rs.Valid = true
+		rs.Schedule = schedule
+		rs.NextRuns = formatRuns(runs, rs.Timezone)
+	}
+	w.WriteJson(rs)
+}
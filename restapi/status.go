@@ -2,15 +2,76 @@ package restapi
 
 import (
 	"expvar"
+	"net/http"
 
 	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/sebest/hooky/models"
+	"github.com/sebest/hooky/store"
 )
 
-func GetStatus(w rest.ResponseWriter, r *rest.Request) {
-	// b := GetBase(r)
-	status := make(map[string]string)
+// Lease is used for the Rest API.
+type Lease struct {
+	// Name is the name of the maintenance job.
+	Name string `json:"name"`
+
+	// Owner is the hookyd instance holding the Lease.
+	Owner string `json:"owner"`
+
+	// Acquired is the date when the owner acquired the Lease.
+	Acquired string `json:"acquired,omitempty"`
+
+	// Expires is the date when the Lease expires if not renewed.
+	Expires string `json:"expires,omitempty"`
+}
+
+// GetStatus returns the handler of the status of a hookyd instance running with role.
+func GetStatus(role string) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, r *rest.Request) {
+		getStatus(w, r, role)
+	}
+}
+
+func getStatus(w rest.ResponseWriter, r *rest.Request, role string) {
+	b := GetBase(r)
+	leases, err := b.GetLeases()
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	status := make(map[string]interface{})
 	status["status"] = "ok"
 	status["attemptsError"] = expvar.Get("attemptsError").String()
 	status["attemptsSuccess"] = expvar.Get("attemptsSuccess").String()
+	status["instance"] = models.Instance
+	status["role"] = role
+	rt := make([]*Lease, len(leases))
+	for idx, lease := range leases {
+		rt[idx] = &Lease{
+			Name:     lease.ID,
+			Owner:    lease.Owner,
+			Acquired: UnixToRFC3339(lease.Acquired),
+			Expires:  UnixToRFC3339(lease.Expires),
+		}
+	}
+	status["leases"] = rt
 	w.WriteJson(status)
 }
+
+// NewStatus creates a Rest API serving only the status, for the instances
+// that do not serve the whole Rest API.
+func NewStatus(s *store.Store, role string) (*rest.Api, error) {
+	api := rest.NewApi()
+	api.Use(rest.DefaultCommonStack...)
+	api.Use(&BaseMiddleware{
+		Store: s,
+	})
+	router, err := rest.MakeRouter(
+		rest.Get("/status", GetStatus(role)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	api.SetApp(router)
+
+	return api, nil
+}
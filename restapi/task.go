@@ -1,6 +1,7 @@
 package restapi
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
@@ -9,6 +10,13 @@ import (
 	"gopkg.in/mgo.v2/bson"
 )
 
+var (
+	// ErrAtAndDelay is returned when both at and delay are provided.
+	ErrAtAndDelay = errors.New("at and delay are mutually exclusive")
+	// ErrAtOnExistingTask is returned when at or delay are provided for an existing task.
+	ErrAtOnExistingTask = errors.New("at and delay only apply when the task is created")
+)
+
 // Task is used for the Rest API.
 type Task struct {
 	// ID is the Task ID.
@@ -47,10 +55,52 @@ type Task struct {
 	// Schedule is a cron specification describing the recurrency if any.
 	Schedule string `json:"schedule,omitempty"`
 
+	// Timezone is the IANA name of the timezone used to evaluate the Schedule.
+	Timezone string `json:"timezone,omitempty"`
+
+	// Misfire is the policy for the runs missed while hooky was down: `skip`, `fire_once` or `fire_all`.
+	Misfire string `json:"misfire,omitempty"`
+
+	// MisfireLimit is the maximum number of missed runs replayed with the `fire_all` policy.
+	MisfireLimit int `json:"misfireLimit,omitempty"`
+
+	// ScheduledAt is the date of the run of the schedule the next attempt belongs to.
+	ScheduledAt string `json:"scheduledAt,omitempty"`
+
+	// Overlap is the policy for the runs starting while the previous attempt is still running: `forbid`, `queue` or `allow`.
+	Overlap string `json:"overlap,omitempty"`
+
+	// Skipped counts the runs skipped because they overlapped the previous attempt.
+	Skipped int `json:"skipped"`
+
+	// StartAt is the date before which the Schedule has no run.
+	StartAt string `json:"startAt,omitempty"`
+
+	// EndAt is the date after which the Schedule has no run.
+	EndAt string `json:"endAt,omitempty"`
+
+	// MaxRuns is the maximum number of runs of the Schedule.
+	MaxRuns int `json:"maxRuns,omitempty"`
+
+	// Runs counts the runs of the Schedule that were executed.
+	Runs int `json:"runs"`
+
+	// Jitter is the maximum delay in seconds added to the runs of the Schedule.
+	Jitter int `json:"jitter,omitempty"`
+
+	// Priority is the priority of the attempts, higher priorities are executed first.
+	Priority int `json:"priority"`
+
+	// NextRuns are the dates of the next attempts of the Schedule.
+	NextRuns []string `json:"nextRuns,omitempty"`
+
 	// At is a date representing the next time a attempt will be executed.
 	At string `json:"at,omitempty"`
 
-	// Status is either `pending`, `retrying`, `canceled`, `success` or `error`
+	// Delay is a duration in seconds to wait before executing the first attempt.
+	Delay int `json:"delay,omitempty"`
+
+	// Status is either `pending`, `retrying`, `canceled`, `success`, `error` or `completed`
 	Status string `json:"status"`
 
 	// Executed is the date of the last time a attempt was executed.
@@ -59,6 +109,12 @@ type Task struct {
 	// Active is the task active.
 	Active *bool `json:"active"`
 
+	// Paused is true when the task was deactivated by a pause.
+	Paused bool `json:"paused"`
+
+	// PausedAt is the date when the task was paused.
+	PausedAt string `json:"pausedAt,omitempty"`
+
 	// Errors counts the number of attempts that failed.
 	Errors int `json:"errors"`
 
@@ -76,34 +132,56 @@ type Task struct {
 
 	// Retry is the retry strategy parameters in case of errors.
 	Retry *models.Retry `json:"retry"`
+
+	// Timeout is the maximum duration of an attempt in seconds.
+	Timeout int `json:"timeout"`
+
+	// Success is the list of HTTP status codes considered as a success.
+	Success string `json:"success"`
 }
 
 // NewTaskFromModel returns a Task object for use with the Rest API
 // from a Task model.
 func NewTaskFromModel(task *models.Task) *Task {
 	return &Task{
-		ID:          task.ID.Hex(),
-		Created:     task.ID.Time().UTC().Format(time.RFC3339),
-		Application: task.Application,
-		Account:     task.Account.Hex(),
-		Queue:       task.Queue,
-		Name:        task.Name,
-		URL:         task.URL,
-		Method:      task.Method,
-		HTTPAuth:    task.HTTPAuth,
-		Headers:     task.Headers,
-		Payload:     task.Payload,
-		Schedule:    task.Schedule,
-		At:          UnixToRFC3339(int64(task.At / 1000000000)),
-		Status:      task.Status,
-		Executed:    UnixToRFC3339(task.Executed),
-		Active:      &task.Active,
-		Executions:  task.Executions,
-		Errors:      task.Errors,
-		LastSuccess: UnixToRFC3339(task.LastSuccess),
-		LastError:   UnixToRFC3339(task.LastError),
-		ErrorRate:   task.ErrorRate(),
-		Retry:       task.Retry,
+		ID:           task.ID.Hex(),
+		Created:      task.ID.Time().UTC().Format(time.RFC3339),
+		Application:  task.Application,
+		Account:      task.Account.Hex(),
+		Queue:        task.Queue,
+		Name:         task.Name,
+		URL:          task.URL,
+		Method:       task.Method,
+		HTTPAuth:     task.HTTPAuth,
+		Headers:      task.Headers,
+		Payload:      task.Payload,
+		Schedule:     task.Schedule,
+		Timezone:     task.Timezone,
+		Misfire:      task.Misfire,
+		MisfireLimit: task.MisfireLimit,
+		ScheduledAt:  UnixToRFC3339(task.ScheduledAt / 1000000000),
+		Overlap:      task.Overlap,
+		Skipped:      task.Skipped,
+		StartAt:      UnixToRFC3339(task.StartAt / 1000000000),
+		EndAt:        UnixToRFC3339(task.EndAt / 1000000000),
+		MaxRuns:      task.MaxRuns,
+		Runs:         task.Runs,
+		Jitter:       task.Jitter,
+		Priority:     task.Priority,
+		At:           UnixToRFC3339(int64(task.At / 1000000000)),
+		Status:       task.Status,
+		Executed:     UnixToRFC3339(task.Executed),
+		Active:       &task.Active,
+		Paused:       task.Paused,
+		PausedAt:     UnixToRFC3339(task.PausedAt),
+		Executions:   task.Executions,
+		Errors:       task.Errors,
+		LastSuccess:  UnixToRFC3339(task.LastSuccess),
+		LastError:    UnixToRFC3339(task.LastError),
+		ErrorRate:    task.ErrorRate(),
+		Retry:        task.Retry,
+		Timeout:      task.Timeout,
+		Success:      task.Success,
 	}
 }
 
@@ -141,7 +219,77 @@ func PutTask(w rest.ResponseWriter, r *rest.Request) {
 		active = *rt.Active
 	}
 	b := GetBase(r)
-	task, err := b.NewTask(accountID, applicationName, taskName, rt.Queue, rt.URL, rt.HTTPAuth, rt.Method, rt.Headers, rt.Payload, rt.Schedule, rt.Retry, active)
+	// at and delay only apply when the task is created: an existing task
+	// follows its schedule, or keeps the time of its pending attempt if it
+	// stays a one-off task.
+	current, err := b.GetTask(accountID, applicationName, taskName)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var at int64
+	if rt.At != "" && rt.Delay != 0 {
+		rest.Error(w, ErrAtAndDelay.Error(), http.StatusInternalServerError)
+		return
+	} else if current != nil {
+		if rt.At != "" || rt.Delay != 0 {
+			rest.Error(w, ErrAtOnExistingTask.Error(), http.StatusInternalServerError)
+			return
+		}
+		if rt.Schedule == "" && current.Schedule == "" && current.At > time.Now().UnixNano() {
+			at = current.At
+		}
+	} else if rt.At != "" {
+		t, err := time.Parse(time.RFC3339, rt.At)
+		if err != nil {
+			rest.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		at = t.UnixNano()
+	} else if rt.Delay != 0 {
+		at = time.Now().Add(time.Duration(rt.Delay) * time.Second).UnixNano()
+	}
+	var startAt, endAt int64
+	if rt.StartAt != "" {
+		t, err := time.Parse(time.RFC3339, rt.StartAt)
+		if err != nil {
+			rest.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		startAt = t.UnixNano()
+	}
+	if rt.EndAt != "" {
+		t, err := time.Parse(time.RFC3339, rt.EndAt)
+		if err != nil {
+			rest.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		endAt = t.UnixNano()
+	}
+	options := models.TaskOptions{
+		Queue:        rt.Queue,
+		URL:          rt.URL,
+		HTTPAuth:     rt.HTTPAuth,
+		Method:       rt.Method,
+		Headers:      rt.Headers,
+		Payload:      rt.Payload,
+		Schedule:     rt.Schedule,
+		Timezone:     rt.Timezone,
+		Misfire:      rt.Misfire,
+		MisfireLimit: rt.MisfireLimit,
+		Overlap:      rt.Overlap,
+		StartAt:      startAt,
+		EndAt:        endAt,
+		MaxRuns:      rt.MaxRuns,
+		Jitter:       rt.Jitter,
+		Priority:     rt.Priority,
+		At:           at,
+		Retry:        rt.Retry,
+		Timeout:      rt.Timeout,
+		Success:      rt.Success,
+		Active:       active,
+	}
+	task, err := b.NewTask(accountID, applicationName, taskName, options)
 	if err != nil {
 		rest.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -167,7 +315,14 @@ func GetTask(w rest.ResponseWriter, r *rest.Request) {
 		rest.NotFound(w, r)
 		return
 	}
-	w.WriteJson(NewTaskFromModel(task))
+	rt := NewTaskFromModel(task)
+	nextRuns, err := task.NextRuns(TaskNextRunsCount)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rt.NextRuns = formatRuns(nextRuns, "UTC")
+	w.WriteJson(rt)
 }
 
 // DeleteTask ...
@@ -238,3 +393,37 @@ func GetTasks(w rest.ResponseWriter, r *rest.Request) {
 		Pages:   lr.Pages,
 	})
 }
+
+// TaskCancel is used to cancel a Task.
+type TaskCancel struct {
+	// Abort is true to abort the request of the running attempt if any.
+	Abort bool `json:"abort"`
+}
+
+// PostTaskCancel ...
+func PostTaskCancel(w rest.ResponseWriter, r *rest.Request) {
+	accountID, applicationName, taskName, err := taskParams(r)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rc := &TaskCancel{}
+	if err := r.DecodeJsonPayload(rc); err != nil {
+		if err != rest.ErrJsonPayloadEmpty {
+			rest.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	b := GetBase(r)
+	task, err := b.CancelTask(accountID, applicationName, taskName, rc.Abort)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if task == nil {
+		rest.NotFound(w, r)
+		return
+	}
+	w.WriteJson(NewTaskFromModel(task))
+}
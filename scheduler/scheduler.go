@@ -1,92 +1,213 @@
 package scheduler
 
 import (
+	"context"
 	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sebest/hooky/models"
 	"github.com/sebest/hooky/store"
 )
 
+const (
+	// maintenanceInterval is the interval between two runs of the maintenance jobs.
+	maintenanceInterval = 60 * time.Second
+
+	// leaseTTL is the duration of the Lease of a maintenance job, the Lease
+	// of a stopped instance is taken over by another instance when it expires.
+	leaseTTL = 2 * maintenanceInterval
+
+	// leaseRenewInterval is the interval between two renewals of the Lease
+	// of a maintenance job while it runs.
+	leaseRenewInterval = leaseTTL / 4
+
+	// minIdleBackoff is the first wait before querying again for attempts when none is due.
+	minIdleBackoff = 50 * time.Millisecond
+
+	// maxIdleBackoff is the longest wait before querying again for attempts,
+	// it bounds the delay to dispatch the attempts created by other instances.
+	maxIdleBackoff = 5 * time.Second
+)
+
 // Scheduler schedules the Attempts of the Tasks.
 type Scheduler struct {
 	store                 *store.Store
 	wg                    sync.WaitGroup
+	maintenance           sync.WaitGroup
 	quit                  chan bool
+	ctx                   context.Context
+	cancel                context.CancelFunc
 	querierSem            chan bool
 	workerSem             chan bool
 	touchInterval         int64
 	cleanFinishedAttempts int64
+	gracePeriod           time.Duration
+	batchSize             int
+	batchWait             time.Duration
+	idleBackoff           int64
 }
 
 // New creates a new Scheduler.
-func New(store *store.Store, maxQuerier int, maxWorker int, touchInterval int, cleanFinishedAttempts int) *Scheduler {
+func New(store *store.Store, maxQuerier int, maxWorker int, touchInterval int, cleanFinishedAttempts int, gracePeriod int, batchSize int, batchWait int) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Scheduler{
 		store:                 store,
 		quit:                  make(chan bool),
+		ctx:                   ctx,
+		cancel:                cancel,
 		querierSem:            make(chan bool, maxQuerier),
 		workerSem:             make(chan bool, maxWorker),
 		touchInterval:         int64(touchInterval),
 		cleanFinishedAttempts: int64(cleanFinishedAttempts),
+		gracePeriod:           time.Duration(gracePeriod) * time.Second,
+		batchSize:             batchSize,
+		batchWait:             time.Duration(batchWait) * time.Millisecond,
 	}
 }
 
-// Stop stops the Scheduler.
+// Stop stops the Scheduler, the attempts still running after the grace
+// period are aborted.
 func (s *Scheduler) Stop() {
 	close(s.quit)
-	s.wg.Wait()
+	done := make(chan bool)
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(s.gracePeriod):
+		log.Println("aborting the running attempts...")
+		s.cancel()
+		<-done
+	}
+	s.cancel()
+	// The maintenance jobs release their Leases once they finish, the Lease
+	// of a job still running expires when it is no more renewed.
+	done = make(chan bool)
+	go func() {
+		s.maintenance.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(s.gracePeriod):
+		log.Println("leaving the maintenance jobs still running...")
+	}
 }
 
-// Start starts the Scheduler.
-func (s *Scheduler) Start() {
-	// Cleaner
-	go func() {
-		clean := func() {
-			db := s.store.DB()
-			defer db.Session.Close()
-			b := models.NewBase(db)
-			if _, err := b.CleanFinishedAttempts(s.cleanFinishedAttempts); err != nil && err != models.ErrDatabase {
-				log.Printf("Scheduler error with CleanFinishedAttempts: %s\n", err)
-			}
-			if err := b.CleanDeletedRessources(); err != nil && err != models.ErrDatabase {
-				log.Printf("Scheduler error with CleanDeletedRessources: %s\n", err)
+// maintain runs the steps of a job every maintenanceInterval while this
+// instance holds the Lease of the job, so a single instance runs it at a
+// time. The Lease is renewed while the job runs and the remaining steps are
+// skipped if it is lost.
+func (s *Scheduler) maintain(name string, steps ...func(b *models.Base)) {
+	defer s.maintenance.Done()
+	defer s.releaseLease(name)
+	run := func() {
+		db := s.store.DB()
+		defer db.Session.Close()
+		b := models.NewBase(db)
+		acquired, err := b.AcquireLease(name, models.Instance, leaseTTL)
+		if err != nil {
+			if err != models.ErrDatabase {
+				log.Printf("Scheduler error with AcquireLease for %s: %s\n", name, err)
 			}
+			return
 		}
-		clean()
-		for {
-			select {
-			case <-s.quit:
+		if !acquired {
+			return
+		}
+		var lost int32
+		stop := make(chan bool)
+		defer close(stop)
+		go s.renewLease(name, &lost, stop)
+		for _, step := range steps {
+			if atomic.LoadInt32(&lost) != 0 {
+				log.Printf("Scheduler lost the lease of %s\n", name)
 				return
-			case <-time.After(time.Second * 60):
-				clean()
 			}
+			step(b)
 		}
-	}()
+	}
+	run()
+	for {
+		select {
+		case <-s.quit:
+			return
+		case <-time.After(maintenanceInterval):
+			run()
+		}
+	}
+}
 
-	// Fixer
-	go func() {
-		fix := func() {
-			db := s.store.DB()
-			defer db.Session.Close()
-			b := models.NewBase(db)
-			if err := b.FixIntegrity(); err != nil && err != models.ErrDatabase {
-				log.Printf("Scheduler error with FixIntegrity: %s\n", err)
-			}
-			if err := b.FixQueues(); err != nil && err != models.ErrDatabase {
-				log.Printf("Scheduler error with FixQueues: %s\n", err)
+// renewLease renews the Lease of a job every leaseRenewInterval until stop is
+// closed, lost is set when the Lease is held by another instance or could
+// not be renewed before it expires.
+func (s *Scheduler) renewLease(name string, lost *int32, stop chan bool) {
+	db := s.store.DB()
+	defer db.Session.Close()
+	b := models.NewBase(db)
+	renewed := time.Now()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(leaseRenewInterval):
+			held, err := b.AcquireLease(name, models.Instance, leaseTTL)
+			if err != nil && err != models.ErrDatabase {
+				log.Printf("Scheduler error with AcquireLease for %s: %s\n", name, err)
 			}
-		}
-		fix()
-		for {
-			select {
-			case <-s.quit:
+			if held {
+				renewed = time.Now()
+			} else if err == nil || time.Since(renewed) >= leaseTTL-leaseRenewInterval {
+				atomic.StoreInt32(lost, 1)
 				return
-			case <-time.After(time.Second * 60):
-				fix()
 			}
 		}
-	}()
+	}
+}
+
+// releaseLease releases the Lease of a job if this instance holds it, so
+// another instance takes over the job without waiting for its expiration.
+func (s *Scheduler) releaseLease(name string) {
+	db := s.store.DB()
+	defer db.Session.Close()
+	b := models.NewBase(db)
+	if err := b.ReleaseLease(name, models.Instance); err != nil && err != models.ErrDatabase {
+		log.Printf("Scheduler error with ReleaseLease for %s: %s\n", name, err)
+	}
+}
+
+// Start starts the Scheduler.
+func (s *Scheduler) Start() {
+	// Cleaner
+	s.maintenance.Add(2)
+	go s.maintain("cleaner", func(b *models.Base) {
+		if _, err := b.CleanFinishedAttempts(s.cleanFinishedAttempts); err != nil && err != models.ErrDatabase {
+			log.Printf("Scheduler error with CleanFinishedAttempts: %s\n", err)
+		}
+	}, func(b *models.Base) {
+		if err := b.CleanDeletedRessources(); err != nil && err != models.ErrDatabase {
+			log.Printf("Scheduler error with CleanDeletedRessources: %s\n", err)
+		}
+	})
+
+	// Fixer
+	go s.maintain("fixer", func(b *models.Base) {
+		if err := b.FixIntegrity(); err != nil && err != models.ErrDatabase {
+			log.Printf("Scheduler error with FixIntegrity: %s\n", err)
+		}
+	}, func(b *models.Base) {
+		if _, err := b.FixQueues(); err != nil && err != models.ErrDatabase {
+			log.Printf("Scheduler error with FixQueues: %s\n", err)
+		}
+	}, func(b *models.Base) {
+		if err := b.AgeAttempts(); err != nil && err != models.ErrDatabase {
+			log.Printf("Scheduler error with AgeAttempts: %s\n", err)
+		}
+	})
 
 	// Attempts scheduler
 	go func() {
@@ -98,25 +219,46 @@ func (s *Scheduler) Start() {
 			case s.querierSem <- true:
 				go func() {
 					s.workerSem <- true
+					slots := 1 + s.acquireWorkers(s.batchSize-1)
 					s.wg.Add(1)
 					defer s.wg.Done()
 					db := s.store.DB()
 					b := models.NewBase(db)
-					attempt, err := b.NextAttempt(s.touchInterval * 2)
+					var attempts []*models.Attempt
+					var err error
+					if slots == 1 {
+						var attempt *models.Attempt
+						if attempt, err = b.NextAttempt(s.touchInterval * 2); attempt != nil {
+							attempts = append(attempts, attempt)
+						}
+					} else {
+						attempts, err = b.NextAttempts(s.touchInterval*2, slots)
+					}
+					var next int64
+					if len(attempts) == 0 && err == nil {
+						next, err = b.NextReserved()
+					}
 					db.Session.Close()
-					if attempt != nil {
+					if err != nil && err != models.ErrDatabase {
+						log.Printf("Scheduler error with NextAttempt: %#v\n", err)
+					}
+					// The attempts admitted before an error hold their slots and are executed.
+					for _, attempt := range attempts {
 						s.wg.Add(1)
-						go func() {
+						go func(attempt *models.Attempt) {
 							defer s.wg.Done()
 							s.worker(attempt)
 							<-s.workerSem
-						}()
-						<-s.querierSem
-						return
-					} else if err != nil && err != models.ErrDatabase {
-						log.Printf("Scheduler error with NextAttempt: %#v\n", err)
+						}(attempt)
+					}
+					for i := len(attempts); i < slots; i++ {
+						<-s.workerSem
+					}
+					if len(attempts) > 0 {
+						atomic.StoreInt64(&s.idleBackoff, 0)
+					} else {
+						s.idleWait(next)
 					}
-					<-s.workerSem
 					<-s.querierSem
 				}()
 			}
@@ -124,6 +266,61 @@ func (s *Scheduler) Start() {
 	}()
 }
 
+// acquireWorkers acquires up to n more slots of the worker pool for a batch of
+// attempts, waiting up to batchWait for busy workers to free them.
+func (s *Scheduler) acquireWorkers(n int) (acquired int) {
+	var timeout <-chan time.Time
+	if s.batchWait > 0 {
+		timeout = time.After(s.batchWait)
+	}
+	for acquired < n {
+		select {
+		case s.workerSem <- true:
+			acquired++
+			continue
+		default:
+		}
+		if timeout == nil {
+			return
+		}
+		select {
+		case s.workerSem <- true:
+			acquired++
+		case <-timeout:
+			return
+		case <-s.quit:
+			return
+		}
+	}
+	return
+}
+
+// idleWait waits before querying again for attempts when none is due. The
+// wait doubles up to maxIdleBackoff while the Scheduler stays idle, ends at
+// the next reserved time if it is sooner, and is interrupted when an attempt
+// due now is created in this process.
+func (s *Scheduler) idleWait(next int64) {
+	backoff := 2 * time.Duration(atomic.LoadInt64(&s.idleBackoff))
+	if backoff < minIdleBackoff {
+		backoff = minIdleBackoff
+	} else if backoff > maxIdleBackoff {
+		backoff = maxIdleBackoff
+	}
+	atomic.StoreInt64(&s.idleBackoff, int64(backoff))
+	wait := backoff
+	if next > 0 {
+		if until := time.Until(time.Unix(0, next)); until < wait {
+			wait = until
+		}
+	}
+	select {
+	case <-s.quit:
+	case <-models.DueAttempts():
+		atomic.StoreInt64(&s.idleBackoff, 0)
+	case <-time.After(wait):
+	}
+}
+
 // worker executes the Attempts.
 func (s *Scheduler) worker(attempt *models.Attempt) {
 	result := make(chan *models.Attempt)
@@ -133,7 +330,9 @@ func (s *Scheduler) worker(attempt *models.Attempt) {
 	db := s.store.DB()
 	defer db.Session.Close()
 	b := models.NewBase(db)
-	// Start a goroutine to touch/reserve the Attempt.
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+	// Start a goroutine to touch/reserve the Attempt and abort it if requested.
 	go func(attempt *models.Attempt) {
 		defer wg.Done()
 		for {
@@ -148,11 +347,13 @@ func (s *Scheduler) worker(attempt *models.Attempt) {
 				}
 				return
 			case <-time.After(time.Duration(s.touchInterval) * time.Second):
-				b.TouchAttempt(attempt.ID, s.touchInterval*2)
+				if abort, _ := b.TouchAttempt(attempt.ID, s.touchInterval*2); abort {
+					cancel()
+				}
 			}
 		}
 	}(attempt)
-	err := b.DoAttempt(attempt)
+	err := b.DoAttempt(ctx, attempt)
 	if err == nil {
 		result <- attempt
 	} else {